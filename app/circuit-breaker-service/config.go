@@ -5,23 +5,88 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/bolt_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/file_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
 	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/server"
 	yaml "gopkg.in/yaml.v3"
 )
 
 type ServiceConfig struct {
-	DefaultPageSize                int `yaml:"default_page_size"`
-	DefaultErrorsThreshold         int `yaml:"default_errors_threshold"`
-	DefaultResetTimeoutMs          int `yaml:"default_reset_timeout_ms"`
-	DefaultErrorsCntResetTimeoutMs int `yaml:"default_errors_cnt_reset_timeout_ms"`
+	// AutoProvision creates the unknown devices from the defaults on their first call, the unknown devices are rejected otherwise.
+	AutoProvision bool `yaml:"auto_provision"`
+
+	// MaxPageSize is the largest page size accepted by the paginated endpoints, 1000 if not set.
+	MaxPageSize int `yaml:"max_page_size"`
+
+	DefaultPageSize                int     `yaml:"default_page_size"` // 10 if not set
+	DefaultErrorsThreshold         int     `yaml:"default_errors_threshold"`
+	DefaultResetTimeoutMs          int     `yaml:"default_reset_timeout_ms"`
+	DefaultErrorsCntResetTimeoutMs int     `yaml:"default_errors_cnt_reset_timeout_ms"`
+	DefaultWindowType              string  `yaml:"default_window_type"`
+	DefaultWindowLengthMs          int     `yaml:"default_window_length_ms"`
+	DefaultWindowBucketsCnt        int     `yaml:"default_window_buckets_cnt"`
+	DefaultWindowSize              int     `yaml:"default_window_size"`
+	DefaultMinimumCalls            int     `yaml:"default_minimum_calls"`
+	DefaultHalfOpenMaxPermits      int     `yaml:"default_half_open_max_permits"`
+	DefaultPermitTimeoutMs         int     `yaml:"default_permit_timeout_ms"`
+	DefaultMaxConcurrentCalls      int     `yaml:"default_max_concurrent_calls"`
+	DefaultBackoffMultiplier       float64 `yaml:"default_backoff_multiplier"`
+	DefaultMaxResetTimeoutMs       int     `yaml:"default_max_reset_timeout_ms"`
+	DefaultSlowCallDurationMs      int     `yaml:"default_slow_call_duration_ms"`
+	DefaultSlowCallRateThreshold   int     `yaml:"default_slow_call_rate_threshold"`
+	DefaultThresholdMode           string  `yaml:"default_threshold_mode"`
+	DefaultBaselineAlpha           float64 `yaml:"default_baseline_alpha"`
+	DefaultBaselineMultiplier      float64 `yaml:"default_baseline_multiplier"`
+	DefaultBaselineDeviations      float64 `yaml:"default_baseline_deviations"`
+	DefaultBaselineWarmupCalls     int     `yaml:"default_baseline_warmup_calls"`
+	DefaultBaselineMinThreshold    float64 `yaml:"default_baseline_min_threshold"`
+	DefaultRampUpDurationMs        int     `yaml:"default_ramp_up_duration_ms"`
+	DefaultRampUpType              string  `yaml:"default_ramp_up_type"`
+	DefaultRetryBudgetPercentage   int     `yaml:"default_retry_budget_percentage"`
+	DefaultRetryBudgetMinRetries   int     `yaml:"default_retry_budget_min_retries"`
+
+	DefaultFailureClassification *model.FailureClassification `yaml:"default_failure_classification"`
+}
+
+const (
+	DatabaseTypeMemory = "memory" // The state is lost on restart, used if the type is not set
+	DatabaseTypeFile   = "file"
+	DatabaseTypeBolt   = "bolt"
+)
+
+// DatabaseConfig selects the storage backend of the circuit breakers, their history and the profiles.
+type DatabaseConfig struct {
+	Type string              `yaml:"type"`
+	File file_storage.Config `yaml:"file"` // Used by DatabaseTypeFile only
+	Bolt bolt_storage.Config `yaml:"bolt"` // Used by DatabaseTypeBolt only
+}
+
+func (c *DatabaseConfig) Validate() error {
+	switch c.Type {
+	case "", DatabaseTypeMemory:
+		return nil
+	case DatabaseTypeFile:
+		if err := c.File.Validate(); err != nil {
+			return fmt.Errorf("invalid file storage config: %w", err)
+		}
+		return nil
+	case DatabaseTypeBolt:
+		if err := c.Bolt.Validate(); err != nil {
+			return fmt.Errorf("invalid bolt storage config: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown database type %q", c.Type)
+	}
 }
 
 type Config struct {
 	LogLevel string `yaml:"log_level"`
 
-	API server.Config `yaml:"api"`
-	// Database                   storage.Config                         `yaml:"db"`
-	Service ServiceConfig `yaml:"service"`
+	API      server.Config  `yaml:"api"`
+	Database DatabaseConfig `yaml:"db"`
+	Service  ServiceConfig  `yaml:"service"`
 }
 
 func loadConfig(configPath string) (*Config, error) {
@@ -41,14 +106,63 @@ func loadConfig(configPath string) (*Config, error) {
 }
 
 func (c *ServiceConfig) Validate() error {
+	if c.DefaultPageSize < 0 || c.MaxPageSize < 0 {
+		return fmt.Errorf("page sizes cannot be negative")
+	}
+	if c.DefaultPageSize > 0 && c.MaxPageSize > 0 && c.DefaultPageSize > c.MaxPageSize {
+		return fmt.Errorf("default page size %d exceeds max page size %d", c.DefaultPageSize, c.MaxPageSize)
+	}
+
+	defaults := c.BreakerDefaults()
+	if err := defaults.Validate(); err != nil {
+		return fmt.Errorf("invalid circuit breaker defaults: %w", err)
+	}
+
 	return nil
 }
 
+// BreakerDefaults returns the config applied to the circuit breaker fields which are not set explicitly.
+func (c *ServiceConfig) BreakerDefaults() model.BreakerConfig {
+	return model.BreakerConfig{
+		ErrorsThreshold:         c.DefaultErrorsThreshold,
+		ErrorsCntResetTimeoutMs: c.DefaultErrorsCntResetTimeoutMs,
+		ResetTimeoutMs:          c.DefaultResetTimeoutMs,
+		WindowType:              c.DefaultWindowType,
+		WindowLengthMs:          c.DefaultWindowLengthMs,
+		WindowBucketsCnt:        c.DefaultWindowBucketsCnt,
+		WindowSize:              c.DefaultWindowSize,
+		MinimumCalls:            c.DefaultMinimumCalls,
+		HalfOpenMaxPermits:      c.DefaultHalfOpenMaxPermits,
+		PermitTimeoutMs:         c.DefaultPermitTimeoutMs,
+		MaxConcurrentCalls:      c.DefaultMaxConcurrentCalls,
+		BackoffMultiplier:       c.DefaultBackoffMultiplier,
+		MaxResetTimeoutMs:       c.DefaultMaxResetTimeoutMs,
+		SlowCallDurationMs:      c.DefaultSlowCallDurationMs,
+		SlowCallRateThreshold:   c.DefaultSlowCallRateThreshold,
+		ThresholdMode:           c.DefaultThresholdMode,
+		BaselineAlpha:           c.DefaultBaselineAlpha,
+		BaselineMultiplier:      c.DefaultBaselineMultiplier,
+		BaselineDeviations:      c.DefaultBaselineDeviations,
+		BaselineWarmupCalls:     c.DefaultBaselineWarmupCalls,
+		BaselineMinThreshold:    c.DefaultBaselineMinThreshold,
+		RampUpDurationMs:        c.DefaultRampUpDurationMs,
+		RampUpType:              c.DefaultRampUpType,
+		RetryBudgetPercentage:   c.DefaultRetryBudgetPercentage,
+		RetryBudgetMinRetries:   c.DefaultRetryBudgetMinRetries,
+
+		FailureClassification: c.DefaultFailureClassification,
+	}
+}
+
 func (c *Config) Validate() error {
 	if err := c.API.Validate(); err != nil {
 		return fmt.Errorf("failed to validate HTTP Server Config, error: '%w'", err)
 	}
 
+	if err := c.Database.Validate(); err != nil {
+		return fmt.Errorf("failed to validate database config, error: '%w'", err)
+	}
+
 	if err := c.Service.Validate(); err != nil {
 		return fmt.Errorf("failed to validate service config, error: '%w'", err)
 	}
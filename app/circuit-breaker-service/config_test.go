@@ -80,3 +80,81 @@ func TestValidateConfigInvalidLogLevel(t *testing.T) {
 		t.Fatalf("Expected validation error for invalid log level, but got none")
 	}
 }
+
+func TestValidateConfigInvalidServiceDefaults(t *testing.T) {
+	// Arrange
+	invalidConfig := main.Config{
+		LogLevel: "info",
+		API: server.Config{
+			ServerHost: "localhost",
+			ServerPort: 8080,
+			AuthKey:    "valid-auth-key",
+		},
+		Service: main.ServiceConfig{
+			DefaultPageSize:                5,
+			DefaultErrorsThreshold:         10,
+			DefaultResetTimeoutMs:          60000,
+			DefaultErrorsCntResetTimeoutMs: 10000,
+			DefaultWindowLengthMs:          10000,
+			DefaultWindowBucketsCnt:        -1,
+		},
+	}
+
+	// Act
+	err := invalidConfig.Validate()
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected validation error for invalid service defaults, but got none")
+	}
+}
+
+func TestValidateConfigDefaultPageSizeExceedsMax(t *testing.T) {
+	// Arrange
+	invalidConfig := main.Config{
+		LogLevel: "info",
+		API: server.Config{
+			ServerHost: "localhost",
+			ServerPort: 8080,
+			AuthKey:    "valid-auth-key",
+		},
+		Service: main.ServiceConfig{
+			MaxPageSize:                    5,
+			DefaultPageSize:                10,
+			DefaultErrorsThreshold:         10,
+			DefaultResetTimeoutMs:          60000,
+			DefaultErrorsCntResetTimeoutMs: 10000,
+		},
+	}
+
+	// Act
+	err := invalidConfig.Validate()
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected validation error for default page size above the max, but got none")
+	}
+}
+
+func TestValidateConfigInvalidDatabase(t *testing.T) {
+	// Arrange
+	invalidConfig := main.Config{
+		LogLevel: "info",
+		API: server.Config{
+			ServerHost: "localhost",
+			ServerPort: 8080,
+			AuthKey:    "valid-auth-key",
+		},
+		Database: main.DatabaseConfig{
+			Type: main.DatabaseTypeFile,
+		},
+	}
+
+	// Act
+	err := invalidConfig.Validate()
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected validation error for missed file storage dir, but got none")
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"log/slog"
 
-	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/map_test_storage"
 	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/server"
 )
 
@@ -19,6 +21,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := cfg.Validate(); err != nil {
+		logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+		logger.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+
 	logger, err := configureLogging(cfg)
 	if err != nil {
 		logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
@@ -29,16 +37,37 @@ func main() {
 	logger.Info("starting service")
 	logger.Info("config loaded", "config", *cfg)
 
-	storage, _ := map_test_storage.New(logger)
+	storages, err := openStorages(&cfg.Database, logger)
+	if err != nil {
+		logger.Error("Failed to open storage", "error", err)
+		os.Exit(3)
+	}
+
+	options := server.Options{
+		Defaults:        cfg.Service.BreakerDefaults(),
+		AutoProvision:   cfg.Service.AutoProvision,
+		DefaultPageSize: cfg.Service.DefaultPageSize,
+		MaxPageSize:     cfg.Service.MaxPageSize,
+	}
 
-	service, err := server.New(&cfg.API, storage, logger)
+	service, err := server.New(&cfg.API, options, storages.entries, storages.history, storages.profiles, logger)
 	if err != nil {
 		logger.Error("Failed to initialize service", "error", err)
+		storages.shutdown(context.Background(), logger)
 		os.Exit(3)
 	}
 
-	if err := service.Run(&cfg.API); err != nil {
-		logger.Error("Server encountered an error", "error", err)
+	// the storages are shut down explicitly once Run returns, a deferred call is skipped by os.Exit
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runErr := service.Run(ctx, &cfg.API)
+	storages.shutdown(context.Background(), logger)
+
+	if runErr != nil {
+		logger.Error("Server encountered an error", "error", runErr)
 		os.Exit(1)
 	}
+
+	logger.Info("service stopped")
 }
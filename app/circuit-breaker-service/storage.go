@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/bolt_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/file_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_history_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/map_test_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+type storages struct {
+	entries  generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry]
+	history  generic_history_storage.HistoryStorageClient[model.Key, model.Transition]
+	profiles generic_storage.StorageClient[string, model.Profile]
+}
+
+// openStorages creates the storage clients of the backend selected by the database config.
+func openStorages(cfg *DatabaseConfig, logger *slog.Logger) (*storages, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+
+	switch cfg.Type {
+	case DatabaseTypeFile:
+		return openFileStorages(&cfg.File, logger)
+	case DatabaseTypeBolt:
+		return openBoltStorages(&cfg.Bolt, logger)
+	default:
+		entries, _ := map_test_storage.New[model.Key, model.CircuitBreakerEntry](logger)
+		history, _ := map_test_storage.NewHistory(logger)
+		profiles, _ := map_test_storage.New[string, model.Profile](logger)
+		return &storages{entries: entries, history: history, profiles: profiles}, nil
+	}
+}
+
+func openFileStorages(cfg *file_storage.Config, logger *slog.Logger) (*storages, error) {
+	entries, err := file_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open entries storage: %w", err)
+	}
+
+	history, err := file_storage.NewHistory(cfg, "history", logger)
+	if err != nil {
+		_ = entries.Shutdown(context.Background())
+		return nil, fmt.Errorf("failed to open history storage: %w", err)
+	}
+
+	profiles, err := file_storage.New[string, model.Profile](cfg, "profiles", logger)
+	if err != nil {
+		_ = entries.Shutdown(context.Background())
+		_ = history.Shutdown(context.Background())
+		return nil, fmt.Errorf("failed to open profiles storage: %w", err)
+	}
+
+	return &storages{entries: entries, history: history, profiles: profiles}, nil
+}
+
+func openBoltStorages(cfg *bolt_storage.Config, logger *slog.Logger) (*storages, error) {
+	entries, err := bolt_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open entries storage: %w", err)
+	}
+
+	history, err := bolt_storage.NewHistory(cfg, "history", logger)
+	if err != nil {
+		_ = entries.Shutdown(context.Background())
+		return nil, fmt.Errorf("failed to open history storage: %w", err)
+	}
+
+	profiles, err := bolt_storage.New[string, model.Profile](cfg, "profiles", logger)
+	if err != nil {
+		_ = entries.Shutdown(context.Background())
+		_ = history.Shutdown(context.Background())
+		return nil, fmt.Errorf("failed to open profiles storage: %w", err)
+	}
+
+	return &storages{entries: entries, history: history, profiles: profiles}, nil
+}
+
+func (s *storages) shutdown(ctx context.Context, logger *slog.Logger) {
+	if err := s.entries.Shutdown(ctx); err != nil {
+		logger.Error("Failed to shut down entries storage", "error", err)
+	}
+	if err := s.history.Shutdown(ctx); err != nil {
+		logger.Error("Failed to shut down history storage", "error", err)
+	}
+	if err := s.profiles.Shutdown(ctx); err != nil {
+		logger.Error("Failed to shut down profiles storage", "error", err)
+	}
+}
@@ -0,0 +1,337 @@
+package bolt_storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Each storage is a single bucket of its own database file, the entries are JSON-encoded.
+// bbolt runs one write transaction at a time, so the version check of CompareAndSwap and the write are atomic,
+// and a batch is committed in a single transaction.
+
+// versioned is the pointer to the entry which version is managed by the storage, see model.CircuitBreakerEntry.
+type versioned[T any] interface {
+	*T
+	GetVersion() uint64
+	SetVersion(version uint64)
+}
+
+// Client is the bbolt-backed generic_storage.StorageClient, K is the primary key and T is the entry.
+type Client[K key, T any, PT versioned[T]] struct {
+	logger *slog.Logger
+	db     *bolt.DB
+	bucket []byte
+}
+
+// New opens the storage kept in the database file named after name in cfg.Dir.
+func New[K key, T any, PT versioned[T]](cfg *Config, name string, logger *slog.Logger) (*Client[K, T, PT], error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("configuration cannot be nil")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("name cannot be empty")
+	}
+
+	if logger == nil {
+		return nil, fmt.Errorf("logger cannot be nil")
+	}
+
+	db, err := openDB(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client[K, T, PT]{
+		logger: logger.With("component", "bolt-storage", "name", name),
+		db:     db,
+		bucket: []byte(name),
+	}, nil
+}
+
+// Shutdown closes the database file, the client cannot be used afterwards.
+func (c *Client[K, T, PT]) Shutdown(ctx context.Context) error {
+	c.logger.Debug("Shutdown called")
+	if err := c.db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	return nil
+}
+
+// IsAlive checks if the storage client is alive.
+func (c *Client[K, T, PT]) IsAlive(ctx context.Context) error {
+	c.logger.Debug("IsAlive called")
+	return c.view(func(b *bolt.Bucket) error {
+		return nil
+	})
+}
+
+// UpsertEntry inserts or updates an entry in the storage.
+func (c *Client[K, T, PT]) UpsertEntry(ctx context.Context, primaryKey K, entry T) error {
+	c.logger.Debug("UpsertEntry called", "primaryKey", primaryKey)
+	return c.update(func(b *bolt.Bucket) error {
+		return c.upsertEntry(b, primaryKey, entry)
+	})
+}
+
+// AddNewEntry adds a new entry to the storage. Fails if the key already exists.
+func (c *Client[K, T, PT]) AddNewEntry(ctx context.Context, primaryKey K, entry T) error {
+	c.logger.Debug("AddNewEntry called", "primaryKey", primaryKey)
+	return c.update(func(b *bolt.Bucket) error {
+		return c.addNewEntry(b, primaryKey, entry)
+	})
+}
+
+// CompareAndSwap updates the entry only if its stored version matches expectedVersion,
+// zero expectedVersion adds a new entry. Fails with generic_storage.ErrConflict otherwise.
+func (c *Client[K, T, PT]) CompareAndSwap(ctx context.Context, primaryKey K, expectedVersion uint64, entry T) error {
+	c.logger.Debug("CompareAndSwap called", "primaryKey", primaryKey, "expectedVersion", expectedVersion)
+	return c.update(func(b *bolt.Bucket) error {
+		return c.compareAndSwap(b, primaryKey, expectedVersion, entry)
+	})
+}
+
+func (c *Client[K, T, PT]) RemoveEntry(ctx context.Context, primaryKey K) error {
+	c.logger.Debug("RemoveEntry called", "primaryKey", primaryKey)
+	return c.update(func(b *bolt.Bucket) error {
+		return c.removeEntry(b, primaryKey)
+	})
+}
+
+// UpsertEntriesBatch inserts or updates entries in the storage.
+func (c *Client[K, T, PT]) UpsertEntriesBatch(ctx context.Context, entries []generic_storage.BatchEntry[K, T]) ([]error, error) {
+	c.logger.Debug("UpsertEntriesBatch called", "entriesCnt", len(entries))
+	return c.batch(len(entries), func(b *bolt.Bucket, i int) error {
+		return c.upsertEntry(b, entries[i].PrimaryKey, entries[i].Entry)
+	})
+}
+
+// AddNewEntriesBatch adds new entries to the storage, the ones which keys already exist are skipped.
+func (c *Client[K, T, PT]) AddNewEntriesBatch(ctx context.Context, entries []generic_storage.BatchEntry[K, T]) ([]error, error) {
+	c.logger.Debug("AddNewEntriesBatch called", "entriesCnt", len(entries))
+	return c.batch(len(entries), func(b *bolt.Bucket, i int) error {
+		return c.addNewEntry(b, entries[i].PrimaryKey, entries[i].Entry)
+	})
+}
+
+// CompareAndSwapBatch updates entries which stored versions match their ExpectedVersion, see CompareAndSwap.
+func (c *Client[K, T, PT]) CompareAndSwapBatch(ctx context.Context, entries []generic_storage.BatchEntry[K, T]) ([]error, error) {
+	c.logger.Debug("CompareAndSwapBatch called", "entriesCnt", len(entries))
+	return c.batch(len(entries), func(b *bolt.Bucket, i int) error {
+		item := entries[i]
+		return c.compareAndSwap(b, item.PrimaryKey, item.ExpectedVersion, item.Entry)
+	})
+}
+
+// RemoveEntriesBatch removes entries from the storage, the missing ones fail with generic_storage.ErrEntryNotFound.
+func (c *Client[K, T, PT]) RemoveEntriesBatch(ctx context.Context, primaryKeys []K) ([]error, error) {
+	c.logger.Debug("RemoveEntriesBatch called", "keysCnt", len(primaryKeys))
+	return c.batch(len(primaryKeys), func(b *bolt.Bucket, i int) error {
+		return c.removeEntry(b, primaryKeys[i])
+	})
+}
+
+func (c *Client[K, T, PT]) GetEntry(ctx context.Context, primaryKey K) (T, error) {
+	c.logger.Debug("GetEntry called", "primaryKey", primaryKey)
+
+	var entry T
+	err := c.view(func(b *bolt.Bucket) error {
+		data := b.Get(encodeKey(primaryKey))
+		if data == nil {
+			err := generic_storage.ErrEntryNotFound
+			c.logger.Debug("GetEntry failed", "primaryKey", primaryKey, "error", err)
+			return err
+		}
+		return decodeEntry(data, &entry)
+	})
+	return entry, err
+}
+
+// GetAllEntries retrieves all entries in the storage ordered by the primary key.
+func (c *Client[K, T, PT]) GetAllEntries(ctx context.Context) ([]T, error) {
+	c.logger.Debug("GetAllEntries called")
+
+	var entries []T
+	err := c.view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(_, data []byte) error {
+			var entry T
+			if err := decodeEntry(data, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetAllEntriesPaginated retrieves a page of entries ordered by the primary key, starting right after the lastPrimaryKey.
+func (c *Client[K, T, PT]) GetAllEntriesPaginated(ctx context.Context, lastPrimaryKey *K, pageSize int) ([]T, error) {
+	c.logger.Debug("GetAllEntriesPaginated called", "lastPrimaryKey", lastPrimaryKey, "pageSize", pageSize)
+
+	entries := []T{}
+	err := c.view(func(b *bolt.Bucket) error {
+		cursor := b.Cursor()
+		k, data := cursor.First()
+		if lastPrimaryKey != nil {
+			last := encodeKey(*lastPrimaryKey)
+			k, data = cursor.Seek(last)
+			if k != nil && string(k) == string(last) {
+				k, data = cursor.Next()
+			}
+		}
+
+		for ; k != nil && len(entries) < pageSize; k, data = cursor.Next() {
+			var entry T
+			if err := decodeEntry(data, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetAllPrimaryKeys retrieves all primary keys in the storage in ascending order.
+func (c *Client[K, T, PT]) GetAllPrimaryKeys(ctx context.Context) ([]K, error) {
+	c.logger.Debug("GetAllPrimaryKeys called")
+
+	var keys []K
+	err := c.view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(k, _ []byte) error {
+			primaryKey, err := decodeKey[K](k)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, primaryKey)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (c *Client[K, T, PT]) view(fn func(b *bolt.Bucket) error) error {
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(c.bucket))
+	})
+	if isClosed(err) {
+		return generic_storage.ErrNotInitialized
+	}
+	return err
+}
+
+func (c *Client[K, T, PT]) update(fn func(b *bolt.Bucket) error) error {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(c.bucket))
+	})
+	if isClosed(err) {
+		return generic_storage.ErrNotInitialized
+	}
+	return err
+}
+
+// batch makes the change of each of n entries in a single transaction, the failed ones do not abort it.
+func (c *Client[K, T, PT]) batch(n int, fn func(b *bolt.Bucket, i int) error) ([]error, error) {
+	results := make([]error, n)
+	err := c.update(func(b *bolt.Bucket) error {
+		for i := range results {
+			results[i] = fn(b, i)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// The helpers below should be called inside the write transaction.
+
+func (c *Client[K, T, PT]) upsertEntry(b *bolt.Bucket, primaryKey K, entry T) error {
+	version, err := storedVersion[T, PT](b, encodeKey(primaryKey))
+	if err != nil {
+		return err
+	}
+	return putEntry[T, PT](b, encodeKey(primaryKey), version+1, entry)
+}
+
+func (c *Client[K, T, PT]) addNewEntry(b *bolt.Bucket, primaryKey K, entry T) error {
+	if b.Get(encodeKey(primaryKey)) != nil {
+		err := generic_storage.ErrEntryAlreadyExists
+		c.logger.Debug("AddNewEntry failed", "primaryKey", primaryKey, "error", err)
+		return err
+	}
+	return putEntry[T, PT](b, encodeKey(primaryKey), 1, entry)
+}
+
+func (c *Client[K, T, PT]) compareAndSwap(b *bolt.Bucket, primaryKey K, expectedVersion uint64, entry T) error {
+	version, err := storedVersion[T, PT](b, encodeKey(primaryKey))
+	if err != nil {
+		return err
+	}
+	if version != expectedVersion {
+		err := generic_storage.ErrConflict
+		c.logger.Debug("CompareAndSwap failed", "primaryKey", primaryKey, "version", version, "error", err)
+		return err
+	}
+	return putEntry[T, PT](b, encodeKey(primaryKey), expectedVersion+1, entry)
+}
+
+func (c *Client[K, T, PT]) removeEntry(b *bolt.Bucket, primaryKey K) error {
+	k := encodeKey(primaryKey)
+	if b.Get(k) == nil {
+		err := generic_storage.ErrEntryNotFound
+		c.logger.Debug("RemoveEntry failed", "primaryKey", primaryKey, "error", err)
+		return err
+	}
+	return b.Delete(k)
+}
+
+// storedVersion returns the version of the stored entry, zero if there is none.
+func storedVersion[T any, PT versioned[T]](b *bolt.Bucket, k []byte) (uint64, error) {
+	data := b.Get(k)
+	if data == nil {
+		return 0, nil
+	}
+
+	var entry T
+	if err := decodeEntry(data, &entry); err != nil {
+		return 0, err
+	}
+	return PT(&entry).GetVersion(), nil
+}
+
+func putEntry[T any, PT versioned[T]](b *bolt.Bucket, k []byte, version uint64, entry T) error {
+	PT(&entry).SetVersion(version)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+	return b.Put(k, data)
+}
+
+func decodeEntry[T any](data []byte, entry *T) error {
+	if err := json.Unmarshal(data, entry); err != nil {
+		return fmt.Errorf("failed to decode entry: %w", err)
+	}
+	return nil
+}
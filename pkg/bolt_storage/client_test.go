@@ -0,0 +1,103 @@
+package bolt_storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/bolt_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/storage_test_suite"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func open(t *testing.T, cfg *bolt_storage.Config) *bolt_storage.Client[model.Key, model.CircuitBreakerEntry, *model.CircuitBreakerEntry] {
+	t.Helper()
+	storage, err := bolt_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	return storage
+}
+
+func TestStorageBehavior(t *testing.T) {
+	storage_test_suite.Run(t, func(t *testing.T) storage_test_suite.Storage {
+		storage := open(t, &bolt_storage.Config{Dir: t.TempDir()})
+		t.Cleanup(func() { _ = storage.Shutdown(context.Background()) })
+		return storage
+	})
+}
+
+func TestReopenKeepsEntries(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &bolt_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+	_ = storage.AddNewEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1, State: model.StateOpen})
+	_ = storage.UpsertEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1, State: model.StateHalfOpen})
+
+	// Act
+	err := storage.Shutdown(ctx)
+	afterShutdown := storage.IsAlive(ctx)
+	reopened := open(t, cfg)
+	defer reopened.Shutdown(ctx)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	if !errors.Is(afterShutdown, generic_storage.ErrNotInitialized) {
+		t.Fatalf("Expected %v, but got: %v", generic_storage.ErrNotInitialized, afterShutdown)
+	}
+	if entry, _ := reopened.GetEntry(ctx, 1); entry.State != model.StateHalfOpen || entry.Version != 2 {
+		t.Fatalf("Expected the entry of version 2 in %v state, but got: %+v", model.StateHalfOpen, entry)
+	}
+}
+
+func TestProfilesPaginatedOrderedByName(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	storage, _ := bolt_storage.New[string, model.Profile](&bolt_storage.Config{Dir: t.TempDir()}, "profiles", logger)
+	defer storage.Shutdown(ctx)
+	for _, name := range []string{"c", "a", "b"} {
+		_ = storage.AddNewEntry(ctx, name, model.Profile{Name: name})
+	}
+	last := "a"
+
+	// Act
+	page, err := storage.GetAllEntriesPaginated(ctx, &last, 5)
+
+	// Assert
+	if err != nil || len(page) != 2 || page[0].Name != "b" || page[1].Name != "c" {
+		t.Fatalf("Expected profiles b and c, but got: %+v, %v", page, err)
+	}
+}
+
+func TestHistoryNewestFirst(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	history, _ := bolt_storage.NewHistory(&bolt_storage.Config{Dir: t.TempDir()}, "history", logger)
+	defer history.Shutdown(ctx)
+	for _, to := range []model.State{model.StateOpen, model.StateHalfOpen, model.StateClosed} {
+		_ = history.AppendRecord(ctx, 1, model.Transition{DeviceID: 1, To: to})
+	}
+	_ = history.AppendRecord(ctx, 2, model.Transition{DeviceID: 2, To: model.StateOpen})
+
+	// Act
+	page, err := history.GetRecordsPaginated(ctx, 1, 1, 5)
+	count, _ := history.CountRecords(ctx, 1)
+	_ = history.RemoveRecords(ctx, 2)
+	removedCount, _ := history.CountRecords(ctx, 2)
+
+	// Assert
+	if err != nil || len(page) != 2 || page[0].To != model.StateHalfOpen || page[1].To != model.StateOpen {
+		t.Fatalf("Expected the 2 older records newest first, but got: %+v, %v", page, err)
+	}
+	if count != 3 || removedCount != 0 {
+		t.Fatalf("Expected 3 and 0 records, but got: %d, %d", count, removedCount)
+	}
+}
@@ -0,0 +1,25 @@
+package bolt_storage
+
+import (
+	"fmt"
+	"time"
+)
+
+type Config struct {
+	// Dir keeps the database file of each storage, it is created if missing.
+	Dir string `yaml:"dir"`
+	// OpenTimeout limits waiting for the lock of the database file held by another process, waits forever if zero.
+	OpenTimeout time.Duration `yaml:"open_timeout"`
+}
+
+func (c *Config) Validate() error {
+	if c.Dir == "" {
+		return fmt.Errorf("missed Dir config param")
+	}
+
+	if c.OpenTimeout < 0 {
+		return fmt.Errorf("OpenTimeout cannot be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package bolt_storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// The model.Key keys are stored big-endian, so the byte order of bbolt matches the numeric order
+// and the paginated reads are native range scans. The string keys are stored as is.
+
+// key is the primary key type supported by the storage.
+type key interface {
+	model.Key | string
+}
+
+func encodeKey[K key](primaryKey K) []byte {
+	switch k := any(primaryKey).(type) {
+	case model.Key:
+		return binary.BigEndian.AppendUint64(nil, uint64(k))
+	case string:
+		return []byte(k)
+	}
+	panic(fmt.Sprintf("unsupported key type %T", primaryKey))
+}
+
+func decodeKey[K key](data []byte) (K, error) {
+	var primaryKey K
+	switch k := any(&primaryKey).(type) {
+	case *model.Key:
+		if len(data) != 8 {
+			return primaryKey, fmt.Errorf("invalid key length %d", len(data))
+		}
+		*k = model.Key(binary.BigEndian.Uint64(data))
+	case *string:
+		*k = string(data)
+	}
+	return primaryKey, nil
+}
+
+// openDB opens the database file named after name in cfg.Dir and creates the bucket of the same name.
+func openDB(cfg *Config, name string) (*bolt.DB, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	db, err := bolt.Open(filepath.Join(cfg.Dir, name+".db"), 0o600, &bolt.Options{Timeout: cfg.OpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(name))
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create bucket: %w", err)
+	}
+
+	return db, nil
+}
+
+// isClosed reports whether the transaction failed because the storage is shut down.
+func isClosed(err error) bool {
+	return errors.Is(err, bolt.ErrDatabaseNotOpen)
+}
@@ -0,0 +1,170 @@
+package bolt_storage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_history_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// The records of each key are kept in a nested bucket of the key, ordered by the big-endian
+// sequence number, so the newest records are read by walking the cursor backwards.
+
+// HistoryClient is the bbolt-backed generic_history_storage.HistoryStorageClient of the state transitions.
+type HistoryClient struct {
+	logger *slog.Logger
+	db     *bolt.DB
+	bucket []byte
+}
+
+// NewHistory opens the history kept in the database file named after name in cfg.Dir.
+func NewHistory(cfg *Config, name string, logger *slog.Logger) (*HistoryClient, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("configuration cannot be nil")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("name cannot be empty")
+	}
+
+	if logger == nil {
+		return nil, fmt.Errorf("logger cannot be nil")
+	}
+
+	db, err := openDB(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HistoryClient{
+		logger: logger.With("component", "bolt-history-storage", "name", name),
+		db:     db,
+		bucket: []byte(name),
+	}, nil
+}
+
+// Shutdown closes the database file, the client cannot be used afterwards.
+func (c *HistoryClient) Shutdown(ctx context.Context) error {
+	c.logger.Debug("Shutdown called")
+	if err := c.db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	return nil
+}
+
+// IsAlive checks if the history storage client is alive.
+func (c *HistoryClient) IsAlive(ctx context.Context) error {
+	c.logger.Debug("IsAlive called")
+	return c.view(func(b *bolt.Bucket) error {
+		return nil
+	})
+}
+
+// AppendRecord stores the transition as the newest record of the key.
+func (c *HistoryClient) AppendRecord(ctx context.Context, primaryKey model.Key, record model.Transition) error {
+	c.logger.Debug("AppendRecord called", "primaryKey", primaryKey, "record", record)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	return c.update(func(b *bolt.Bucket) error {
+		records, err := b.CreateBucketIfNotExists(encodeKey(primaryKey))
+		if err != nil {
+			return fmt.Errorf("failed to create bucket: %w", err)
+		}
+
+		seq, err := records.NextSequence()
+		if err != nil {
+			return fmt.Errorf("failed to get sequence: %w", err)
+		}
+		return records.Put(binary.BigEndian.AppendUint64(nil, seq), data)
+	})
+}
+
+// GetRecordsPaginated retrieves up to pageSize records of the key newest first, skipping offset newest records.
+func (c *HistoryClient) GetRecordsPaginated(ctx context.Context, primaryKey model.Key, offset int, pageSize int) ([]model.Transition, error) {
+	c.logger.Debug("GetRecordsPaginated called", "primaryKey", primaryKey, "offset", offset, "pageSize", pageSize)
+
+	records := []model.Transition{}
+	err := c.view(func(b *bolt.Bucket) error {
+		stored := b.Bucket(encodeKey(primaryKey))
+		if stored == nil {
+			return nil
+		}
+
+		cursor := stored.Cursor()
+		_, data := cursor.Last()
+		for skipped := 0; data != nil && skipped < offset; skipped++ {
+			_, data = cursor.Prev()
+		}
+
+		for ; data != nil && len(records) < pageSize; _, data = cursor.Prev() {
+			var record model.Transition
+			if err := json.Unmarshal(data, &record); err != nil {
+				return fmt.Errorf("failed to decode record: %w", err)
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// CountRecords returns the number of records of the key.
+func (c *HistoryClient) CountRecords(ctx context.Context, primaryKey model.Key) (int, error) {
+	c.logger.Debug("CountRecords called", "primaryKey", primaryKey)
+
+	var count int
+	err := c.view(func(b *bolt.Bucket) error {
+		if stored := b.Bucket(encodeKey(primaryKey)); stored != nil {
+			count = stored.Stats().KeyN
+		}
+		return nil
+	})
+	return count, err
+}
+
+// RemoveRecords removes all records of the key.
+func (c *HistoryClient) RemoveRecords(ctx context.Context, primaryKey model.Key) error {
+	c.logger.Debug("RemoveRecords called", "primaryKey", primaryKey)
+	return c.update(func(b *bolt.Bucket) error {
+		if b.Bucket(encodeKey(primaryKey)) == nil {
+			return nil
+		}
+		return b.DeleteBucket(encodeKey(primaryKey))
+	})
+}
+
+func (c *HistoryClient) view(fn func(b *bolt.Bucket) error) error {
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(c.bucket))
+	})
+	if isClosed(err) {
+		return generic_history_storage.ErrNotInitialized
+	}
+	return err
+}
+
+func (c *HistoryClient) update(fn func(b *bolt.Bucket) error) error {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(c.bucket))
+	})
+	if isClosed(err) {
+		return generic_history_storage.ErrNotInitialized
+	}
+	return err
+}
@@ -0,0 +1,208 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/state_machine"
+)
+
+// The in-process breaker shares the state machine with the service,
+// so a breaker configured with the same model.BreakerConfig behaves the same way.
+// There is no scheduler in-process, the open breaker is moved to the half-open state
+// lazily when the next call is attempted.
+
+// ErrNotAllowed is returned by Execute when the circuit breaker denies the call.
+var ErrNotAllowed = errors.New("breaker: call not allowed")
+
+// Breaker protects the calls to a single device.
+type Breaker struct {
+	mu    sync.Mutex
+	entry model.CircuitBreakerEntry
+}
+
+// New creates a closed circuit breaker.
+func New(deviceID model.Key, cfg model.BreakerConfig) *Breaker {
+	return &Breaker{
+		entry: model.CircuitBreakerEntry{
+			DeviceID:      deviceID,
+			State:         model.StateClosed,
+			LastChanged:   time.Now(),
+			BreakerConfig: cfg,
+		},
+	}
+}
+
+// Load restores the circuit breaker from storage.
+func Load(ctx context.Context, storage generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry], deviceID model.Key) (*Breaker, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage cannot be nil")
+	}
+
+	entry, err := storage.GetEntry(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entry: %w", err)
+	}
+
+	return &Breaker{entry: entry.Clone()}, nil
+}
+
+// Save stores the state of the circuit breaker.
+func (b *Breaker) Save(ctx context.Context, storage generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry]) error {
+	if storage == nil {
+		return fmt.Errorf("storage cannot be nil")
+	}
+
+	entry := b.Entry()
+	if err := storage.UpsertEntry(ctx, entry.DeviceID, entry); err != nil {
+		return fmt.Errorf("failed to upsert entry: %w", err)
+	}
+
+	return nil
+}
+
+// SaveAll stores the state of many circuit breakers in a single batch.
+// Returns the result of each circuit breaker in the order of breakers, nil if it was stored.
+func SaveAll(ctx context.Context, storage generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry], breakers []*Breaker) ([]error, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage cannot be nil")
+	}
+
+	batch := make([]generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry], 0, len(breakers))
+	for _, b := range breakers {
+		entry := b.Entry()
+		batch = append(batch, generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry]{
+			PrimaryKey: entry.DeviceID,
+			Entry:      entry,
+		})
+	}
+
+	results, err := storage.UpsertEntriesBatch(ctx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert entries: %w", err)
+	}
+
+	return results, nil
+}
+
+// Entry returns a snapshot of the circuit breaker.
+func (b *Breaker) Entry() model.CircuitBreakerEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(time.Now())
+	return b.entry.Clone()
+}
+
+// State returns the current state of the circuit breaker, the override is taken into account.
+func (b *Breaker) State() model.State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.advance(now)
+	return state_machine.EffectiveState(&b.entry, now)
+}
+
+// SetOverride pins the circuit breaker to the override mode until expiresAt, forever if expiresAt is zero.
+func (b *Breaker) SetOverride(mode string, reason string, expiresAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state_machine.SetOverride(&b.entry, mode, reason, expiresAt, time.Now())
+}
+
+// ClearOverride removes the override, if any.
+func (b *Breaker) ClearOverride() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state_machine.ClearOverride(&b.entry, time.Now())
+}
+
+// Reset moves the circuit breaker to StateClosed.
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state_machine.Reset(&b.entry, time.Now())
+}
+
+// Execute calls fn if the circuit breaker allows it and reports the outcome, any error returned by fn
+// is counted as a failure. The error of the cancelled or expired ctx tells nothing about the device,
+// the permit is released without reporting then. A panic in fn is reported as a failure and propagated.
+// Returns ErrNotAllowed without calling fn if the call is denied.
+func Execute[T any](ctx context.Context, b *Breaker, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+
+	decision := b.acquire()
+	if !decision.Allowed {
+		return zero, ErrNotAllowed
+	}
+
+	startedAt := time.Now()
+	returned := false
+	defer func() {
+		if !returned {
+			b.report(state_machine.Call{
+				Failed:        true,
+				FailureReason: "panic",
+				PermitID:      decision.PermitID,
+				Duration:      time.Since(startedAt),
+			})
+		}
+	}()
+	result, err := fn(ctx)
+	returned = true
+
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		b.release(decision.PermitID)
+		return result, err
+	}
+
+	call := state_machine.Call{
+		Failed:   err != nil,
+		PermitID: decision.PermitID,
+		Duration: time.Since(startedAt),
+	}
+	if err != nil {
+		call.FailureReason = err.Error()
+	}
+	b.report(call)
+
+	return result, err
+}
+
+func (b *Breaker) acquire() state_machine.Decision {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.advance(now)
+	return state_machine.Acquire(&b.entry, now)
+}
+
+func (b *Breaker) release(permitID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state_machine.Release(&b.entry, permitID)
+}
+
+func (b *Breaker) report(call state_machine.Call) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state_machine.Report(&b.entry, call, time.Now())
+}
+
+// advance applies the transitions which are due by now, they are made by the scheduler in the service.
+func (b *Breaker) advance(now time.Time) {
+	state_machine.ExpireOverride(&b.entry, now)
+	state_machine.HalfOpen(&b.entry, now)
+}
@@ -0,0 +1,153 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/breaker"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/map_test_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+var errDevice = errors.New("device error")
+
+func TestExecuteTripsAndDenies(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	b := breaker.New(1, model.BreakerConfig{
+		ErrorsThreshold: 50,
+		WindowLengthMs:  10000,
+		MinimumCalls:    2,
+		ResetTimeoutMs:  60000,
+	})
+	fail := func(context.Context) (int, error) { return 0, errDevice }
+	calls := 0
+	succeed := func(context.Context) (int, error) { calls++; return 42, nil }
+
+	// Act
+	result, err := breaker.Execute(ctx, b, succeed)
+	_, _ = breaker.Execute(ctx, b, fail)
+	_, denied := breaker.Execute(ctx, b, succeed)
+
+	// Assert
+	if err != nil || result != 42 {
+		t.Fatalf("Expected result 42 without errors, but got: %d, %v", result, err)
+	}
+	if b.State() != model.StateOpen {
+		t.Fatalf("Expected state %v, but got: %v", model.StateOpen, b.State())
+	}
+	if !errors.Is(denied, breaker.ErrNotAllowed) || calls != 1 {
+		t.Fatalf("Expected the call to be denied without calling fn, but got: %v, %d calls", denied, calls)
+	}
+}
+
+func TestExecuteHalfOpenTrialCloses(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	b := breaker.New(1, model.BreakerConfig{
+		ErrorsThreshold: 50,
+		WindowLengthMs:  10000,
+		ResetTimeoutMs:  10,
+	})
+	_, _ = breaker.Execute(ctx, b, func(context.Context) (struct{}, error) { return struct{}{}, errDevice })
+	time.Sleep(20 * time.Millisecond)
+
+	// Act
+	_, err := breaker.Execute(ctx, b, func(context.Context) (string, error) { return "ok", nil })
+
+	// Assert
+	if err != nil || b.State() != model.StateClosed {
+		t.Fatalf("Expected state %v after the trial call, but got: %v, %v", model.StateClosed, b.State(), err)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	storage, _ := map_test_storage.New[model.Key, model.CircuitBreakerEntry](slog.New(slog.NewTextHandler(io.Discard, nil)))
+	b := breaker.New(7, model.BreakerConfig{ErrorsThreshold: 50, ResetTimeoutMs: 60000})
+	_, _ = breaker.Execute(ctx, b, func(context.Context) (int, error) { return 0, errDevice })
+
+	// Act
+	if err := b.Save(ctx, storage); err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	loaded, err := breaker.Load(ctx, storage, 7)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	if loaded.State() != model.StateOpen || loaded.Entry().LastFailureReason != errDevice.Error() {
+		t.Fatalf("Expected the saved state to be restored, but got: %+v", loaded.Entry())
+	}
+}
+
+func TestSaveAll(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	storage, _ := map_test_storage.New[model.Key, model.CircuitBreakerEntry](slog.New(slog.NewTextHandler(io.Discard, nil)))
+	config := model.BreakerConfig{ErrorsThreshold: 50, ResetTimeoutMs: 60000}
+	failing := breaker.New(8, config)
+	healthy := breaker.New(9, config)
+	_, _ = breaker.Execute(ctx, failing, func(context.Context) (int, error) { return 0, errDevice })
+
+	// Act
+	results, err := breaker.SaveAll(ctx, storage, []*breaker.Breaker{failing, healthy})
+
+	// Assert
+	if err != nil || len(results) != 2 || results[0] != nil || results[1] != nil {
+		t.Fatalf("Expected both breakers to be stored, but got: %v, %v", results, err)
+	}
+	if entry, _ := storage.GetEntry(ctx, 8); entry.State != model.StateOpen {
+		t.Fatalf("Expected state %v, but got: %v", model.StateOpen, entry.State)
+	}
+	if entry, _ := storage.GetEntry(ctx, 9); entry.State != model.StateClosed {
+		t.Fatalf("Expected state %v, but got: %v", model.StateClosed, entry.State)
+	}
+}
+
+func TestExecuteReportsPanicAsFailure(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	b := breaker.New(1, model.BreakerConfig{ErrorsThreshold: 50, WindowLengthMs: 10000, ResetTimeoutMs: 60000})
+
+	// Act
+	recovered := func() (recovered any) {
+		defer func() { recovered = recover() }()
+		_, _ = breaker.Execute(ctx, b, func(context.Context) (int, error) { panic("device driver crashed") })
+		return nil
+	}()
+
+	// Assert
+	if recovered != "device driver crashed" {
+		t.Fatalf("Expected the panic to be propagated, but got: %v", recovered)
+	}
+	if entry := b.Entry(); entry.State != model.StateOpen || len(entry.Permits) != 0 {
+		t.Fatalf("Expected the panic to be reported as a failure, but got: %v, %d permits", entry.State, len(entry.Permits))
+	}
+}
+
+func TestExecuteIgnoresCancelledContext(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	b := breaker.New(1, model.BreakerConfig{ErrorsThreshold: 50, WindowLengthMs: 10000, ResetTimeoutMs: 60000})
+
+	// Act
+	_, err := breaker.Execute(ctx, b, func(ctx context.Context) (int, error) {
+		cancel()
+		return 0, ctx.Err()
+	})
+
+	// Assert
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the context error to be returned, but got: %v", err)
+	}
+	if entry := b.Entry(); entry.State != model.StateClosed || len(entry.Permits) != 0 {
+		t.Fatalf("Expected the permit to be released without a failure, but got: %v, %d permits", entry.State, len(entry.Permits))
+	}
+}
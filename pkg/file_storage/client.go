@@ -0,0 +1,561 @@
+package file_storage
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+)
+
+// The whole state is kept in memory and served from there, the files are read only on New.
+// Each write is appended to the WAL before it is applied in memory, a batch is a single group of WAL records,
+// so it is recovered either entirely or not at all. The WAL is compacted into the snapshot every
+// SnapshotInterval and on Shutdown.
+
+// versioned is the pointer to the entry which version is managed by the storage, see model.CircuitBreakerEntry.
+type versioned[T any] interface {
+	*T
+	GetVersion() uint64
+	SetVersion(version uint64)
+}
+
+// Client is the file-backed generic_storage.StorageClient, K is the primary key and T is the entry.
+type Client[K cmp.Ordered, T any, PT versioned[T]] struct {
+	logger *slog.Logger
+
+	mu      sync.RWMutex
+	journal *journal // nil once the client is shut down
+	entries map[K]storedEntry
+	keys    []K // Sorted, serves the paginated reads
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// storedEntry keeps the entry encoded, so each read returns a copy which can be changed freely.
+type storedEntry struct {
+	version uint64
+	data    []byte
+}
+
+// walRecord is the payload of the WAL and snapshot records.
+type walRecord[K any] struct {
+	Ops []walOp[K] `json:"ops"`
+}
+
+type walOp[K any] struct {
+	Key   K               `json:"key"`
+	Entry json.RawMessage `json:"entry,omitempty"` // The entry is removed if missing
+}
+
+// New opens the storage kept in the files named after name in cfg.Dir, the previous state is recovered.
+func New[K cmp.Ordered, T any, PT versioned[T]](cfg *Config, name string, logger *slog.Logger) (*Client[K, T, PT], error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("configuration cannot be nil")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("name cannot be empty")
+	}
+
+	if logger == nil {
+		return nil, fmt.Errorf("logger cannot be nil")
+	}
+
+	c := &Client[K, T, PT]{
+		logger:  logger.With("component", "file-storage", "name", name),
+		entries: make(map[K]storedEntry),
+	}
+
+	journal, err := openJournal(cfg.Dir, name, c.logger, c.replay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	c.journal = journal
+	c.logger.Info("Storage loaded", "entriesCnt", len(c.keys), "walSize", journal.walSize)
+
+	if cfg.SnapshotInterval > 0 {
+		c.stop = make(chan struct{})
+		c.done = make(chan struct{})
+		go runEvery(cfg.SnapshotInterval, c.stop, c.done, c.compactPeriodically)
+	}
+
+	return c, nil
+}
+
+// Shutdown compacts the WAL into the snapshot and closes the files, the client cannot be used afterwards.
+func (c *Client[K, T, PT]) Shutdown(ctx context.Context) error {
+	c.logger.Debug("Shutdown called")
+
+	c.mu.Lock()
+	if c.journal == nil {
+		c.mu.Unlock()
+		return generic_storage.ErrNotInitialized
+	}
+
+	compactErr := c.compact()
+	closeErr := c.journal.close()
+	c.journal = nil
+	c.mu.Unlock()
+
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+	}
+
+	if compactErr != nil {
+		return fmt.Errorf("failed to compact: %w", compactErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close WAL: %w", closeErr)
+	}
+	return nil
+}
+
+// IsAlive checks if the storage client is alive.
+func (c *Client[K, T, PT]) IsAlive(ctx context.Context) error {
+	c.logger.Debug("IsAlive called")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.journal == nil {
+		return generic_storage.ErrNotInitialized
+	}
+	return nil
+}
+
+// UpsertEntry inserts or updates an entry in the storage.
+func (c *Client[K, T, PT]) UpsertEntry(ctx context.Context, primaryKey K, entry T) error {
+	c.logger.Debug("UpsertEntry called", "primaryKey", primaryKey)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return generic_storage.ErrNotInitialized
+	}
+
+	var w write[K]
+	if err := c.put(&w, primaryKey, c.entries[primaryKey].version+1, entry); err != nil {
+		return err
+	}
+	return c.commit(&w)
+}
+
+// AddNewEntry adds a new entry to the storage. Fails if the key already exists.
+func (c *Client[K, T, PT]) AddNewEntry(ctx context.Context, primaryKey K, entry T) error {
+	c.logger.Debug("AddNewEntry called", "primaryKey", primaryKey)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return generic_storage.ErrNotInitialized
+	}
+
+	var w write[K]
+	if err := c.addNewEntry(&w, primaryKey, entry); err != nil {
+		return err
+	}
+	return c.commit(&w)
+}
+
+// CompareAndSwap updates the entry only if its stored version matches expectedVersion,
+// zero expectedVersion adds a new entry. Fails with generic_storage.ErrConflict otherwise.
+func (c *Client[K, T, PT]) CompareAndSwap(ctx context.Context, primaryKey K, expectedVersion uint64, entry T) error {
+	c.logger.Debug("CompareAndSwap called", "primaryKey", primaryKey, "expectedVersion", expectedVersion)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return generic_storage.ErrNotInitialized
+	}
+
+	var w write[K]
+	if err := c.compareAndSwap(&w, primaryKey, expectedVersion, entry); err != nil {
+		return err
+	}
+	return c.commit(&w)
+}
+
+func (c *Client[K, T, PT]) RemoveEntry(ctx context.Context, primaryKey K) error {
+	c.logger.Debug("RemoveEntry called", "primaryKey", primaryKey)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return generic_storage.ErrNotInitialized
+	}
+
+	var w write[K]
+	if err := c.removeEntry(&w, primaryKey); err != nil {
+		return err
+	}
+	return c.commit(&w)
+}
+
+// UpsertEntriesBatch inserts or updates entries in the storage.
+func (c *Client[K, T, PT]) UpsertEntriesBatch(ctx context.Context, entries []generic_storage.BatchEntry[K, T]) ([]error, error) {
+	c.logger.Debug("UpsertEntriesBatch called", "entriesCnt", len(entries))
+
+	return c.batch(len(entries), func(w *write[K], i int) error {
+		item := entries[i]
+		return c.put(w, item.PrimaryKey, c.entries[item.PrimaryKey].version+1, item.Entry)
+	})
+}
+
+// AddNewEntriesBatch adds new entries to the storage, the ones which keys already exist are skipped.
+func (c *Client[K, T, PT]) AddNewEntriesBatch(ctx context.Context, entries []generic_storage.BatchEntry[K, T]) ([]error, error) {
+	c.logger.Debug("AddNewEntriesBatch called", "entriesCnt", len(entries))
+
+	return c.batch(len(entries), func(w *write[K], i int) error {
+		return c.addNewEntry(w, entries[i].PrimaryKey, entries[i].Entry)
+	})
+}
+
+// CompareAndSwapBatch updates entries which stored versions match their ExpectedVersion, see CompareAndSwap.
+func (c *Client[K, T, PT]) CompareAndSwapBatch(ctx context.Context, entries []generic_storage.BatchEntry[K, T]) ([]error, error) {
+	c.logger.Debug("CompareAndSwapBatch called", "entriesCnt", len(entries))
+
+	return c.batch(len(entries), func(w *write[K], i int) error {
+		item := entries[i]
+		return c.compareAndSwap(w, item.PrimaryKey, item.ExpectedVersion, item.Entry)
+	})
+}
+
+// RemoveEntriesBatch removes entries from the storage, the missing ones fail with generic_storage.ErrEntryNotFound.
+func (c *Client[K, T, PT]) RemoveEntriesBatch(ctx context.Context, primaryKeys []K) ([]error, error) {
+	c.logger.Debug("RemoveEntriesBatch called", "keysCnt", len(primaryKeys))
+
+	return c.batch(len(primaryKeys), func(w *write[K], i int) error {
+		return c.removeEntry(w, primaryKeys[i])
+	})
+}
+
+func (c *Client[K, T, PT]) GetEntry(ctx context.Context, primaryKey K) (T, error) {
+	c.logger.Debug("GetEntry called", "primaryKey", primaryKey)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var entry T
+	if c.journal == nil {
+		return entry, generic_storage.ErrNotInitialized
+	}
+
+	stored, exists := c.entries[primaryKey]
+	if !exists {
+		err := generic_storage.ErrEntryNotFound
+		c.logger.Debug("GetEntry failed", "primaryKey", primaryKey, "error", err)
+		return entry, err
+	}
+	return c.decode(stored)
+}
+
+// GetAllEntries retrieves all entries in the storage ordered by the primary key.
+func (c *Client[K, T, PT]) GetAllEntries(ctx context.Context) ([]T, error) {
+	c.logger.Debug("GetAllEntries called")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.journal == nil {
+		return nil, generic_storage.ErrNotInitialized
+	}
+	return c.decodeAll(c.keys)
+}
+
+// GetAllEntriesPaginated retrieves a page of entries ordered by the primary key, starting right after the lastPrimaryKey.
+func (c *Client[K, T, PT]) GetAllEntriesPaginated(ctx context.Context, lastPrimaryKey *K, pageSize int) ([]T, error) {
+	c.logger.Debug("GetAllEntriesPaginated called", "lastPrimaryKey", lastPrimaryKey, "pageSize", pageSize)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.journal == nil {
+		return nil, generic_storage.ErrNotInitialized
+	}
+
+	first := 0
+	if lastPrimaryKey != nil {
+		var found bool
+		first, found = slices.BinarySearch(c.keys, *lastPrimaryKey)
+		if found {
+			first++
+		}
+	}
+	last := min(first+max(pageSize, 0), len(c.keys))
+
+	return c.decodeAll(c.keys[first:last])
+}
+
+// GetAllPrimaryKeys retrieves all primary keys in the storage in ascending order.
+func (c *Client[K, T, PT]) GetAllPrimaryKeys(ctx context.Context) ([]K, error) {
+	c.logger.Debug("GetAllPrimaryKeys called")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.journal == nil {
+		return nil, generic_storage.ErrNotInitialized
+	}
+	return slices.Clone(c.keys), nil
+}
+
+// write collects the changes which are committed to the WAL as a single record.
+// The changes are applied in memory right away, so the next ones see them, and are undone if the commit fails.
+type write[K any] struct {
+	ops  []walOp[K]
+	undo []func()
+}
+
+// The helpers below should be called with c.mu held.
+
+// batch stages the change of each of n entries and commits the ones which succeeded together.
+func (c *Client[K, T, PT]) batch(n int, stage func(w *write[K], i int) error) ([]error, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return nil, generic_storage.ErrNotInitialized
+	}
+
+	var w write[K]
+	results := make([]error, n)
+	for i := range results {
+		results[i] = stage(&w, i)
+	}
+
+	if err := c.commit(&w); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (c *Client[K, T, PT]) addNewEntry(w *write[K], primaryKey K, entry T) error {
+	if _, exists := c.entries[primaryKey]; exists {
+		err := generic_storage.ErrEntryAlreadyExists
+		c.logger.Debug("AddNewEntry failed", "primaryKey", primaryKey, "error", err)
+		return err
+	}
+	return c.put(w, primaryKey, 1, entry)
+}
+
+func (c *Client[K, T, PT]) compareAndSwap(w *write[K], primaryKey K, expectedVersion uint64, entry T) error {
+	if version := c.entries[primaryKey].version; version != expectedVersion {
+		err := generic_storage.ErrConflict
+		c.logger.Debug("CompareAndSwap failed", "primaryKey", primaryKey, "version", version, "error", err)
+		return err
+	}
+	return c.put(w, primaryKey, expectedVersion+1, entry)
+}
+
+func (c *Client[K, T, PT]) removeEntry(w *write[K], primaryKey K) error {
+	if _, exists := c.entries[primaryKey]; !exists {
+		err := generic_storage.ErrEntryNotFound
+		c.logger.Debug("RemoveEntry failed", "primaryKey", primaryKey, "error", err)
+		return err
+	}
+	c.stage(w, walOp[K]{Key: primaryKey}, nil)
+	return nil
+}
+
+func (c *Client[K, T, PT]) put(w *write[K], primaryKey K, version uint64, entry T) error {
+	PT(&entry).SetVersion(version)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+	c.stage(w, walOp[K]{Key: primaryKey, Entry: data}, &storedEntry{version: version, data: data})
+	return nil
+}
+
+// stage applies the change in memory, nil stored removes the entry.
+func (c *Client[K, T, PT]) stage(w *write[K], op walOp[K], stored *storedEntry) {
+	previous, existed := c.entries[op.Key]
+	w.undo = append(w.undo, func() {
+		if existed {
+			c.set(op.Key, previous)
+		} else {
+			c.delete(op.Key)
+		}
+	})
+	w.ops = append(w.ops, op)
+
+	if stored == nil {
+		c.delete(op.Key)
+	} else {
+		c.set(op.Key, *stored)
+	}
+}
+
+// commit appends the staged changes to the WAL, they are undone if it fails.
+func (c *Client[K, T, PT]) commit(w *write[K]) error {
+	if len(w.ops) == 0 {
+		return nil
+	}
+
+	payloads, err := encodeOps(w.ops)
+	if err == nil {
+		err = c.journal.append(payloads...)
+	}
+	if err != nil {
+		for i := len(w.undo) - 1; i >= 0; i-- {
+			w.undo[i]()
+		}
+		c.logger.Error("Failed to commit write", "opsCnt", len(w.ops), "error", err)
+		return fmt.Errorf("failed to commit write: %w", err)
+	}
+
+	return nil
+}
+
+// encodeOps splits the ops into the payloads of the WAL records which fit into maxRecordSize,
+// the journal appends them as a single group, so the write is still recovered either entirely or not at all.
+func encodeOps[K any](ops []walOp[K]) ([][]byte, error) {
+	// opOverhead covers the field names and the separators of the op, recordOverhead the ones of the record
+	const opOverhead, recordOverhead = 32, 64
+
+	var payloads [][]byte
+	start, size := 0, recordOverhead
+	for i, op := range ops {
+		key, err := json.Marshal(op.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode key: %w", err)
+		}
+
+		opSize := len(key) + len(op.Entry) + opOverhead
+		if i > start && size+opSize > maxRecordSize {
+			payload, err := json.Marshal(walRecord[K]{Ops: ops[start:i]})
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode record: %w", err)
+			}
+			payloads = append(payloads, payload)
+			start, size = i, recordOverhead
+		}
+		size += opSize
+	}
+
+	payload, err := json.Marshal(walRecord[K]{Ops: ops[start:]})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+	return append(payloads, payload), nil
+}
+
+func (c *Client[K, T, PT]) replay(payload []byte) error {
+	var record walRecord[K]
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return fmt.Errorf("failed to decode record: %w", err)
+	}
+
+	for _, op := range record.Ops {
+		if err := c.apply(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client[K, T, PT]) apply(op walOp[K]) error {
+	if op.Entry == nil {
+		c.delete(op.Key)
+		return nil
+	}
+
+	var entry T
+	if err := json.Unmarshal(op.Entry, &entry); err != nil {
+		return fmt.Errorf("failed to decode entry: %w", err)
+	}
+	c.set(op.Key, storedEntry{version: PT(&entry).GetVersion(), data: op.Entry})
+	return nil
+}
+
+func (c *Client[K, T, PT]) set(primaryKey K, stored storedEntry) {
+	if _, exists := c.entries[primaryKey]; !exists {
+		i, _ := slices.BinarySearch(c.keys, primaryKey)
+		c.keys = slices.Insert(c.keys, i, primaryKey)
+	}
+	c.entries[primaryKey] = stored
+}
+
+func (c *Client[K, T, PT]) delete(primaryKey K) {
+	if _, exists := c.entries[primaryKey]; !exists {
+		return
+	}
+	i, _ := slices.BinarySearch(c.keys, primaryKey)
+	c.keys = slices.Delete(c.keys, i, i+1)
+	delete(c.entries, primaryKey)
+}
+
+func (c *Client[K, T, PT]) decode(stored storedEntry) (T, error) {
+	var entry T
+	if err := json.Unmarshal(stored.data, &entry); err != nil {
+		return entry, fmt.Errorf("failed to decode entry: %w", err)
+	}
+	return entry, nil
+}
+
+func (c *Client[K, T, PT]) decodeAll(keys []K) ([]T, error) {
+	entries := make([]T, 0, len(keys))
+	for _, key := range keys {
+		entry, err := c.decode(c.entries[key])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (c *Client[K, T, PT]) compactPeriodically() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return
+	}
+	if err := c.compact(); err != nil {
+		c.logger.Error("Failed to compact WAL", "error", err)
+	}
+}
+
+// compact writes each entry as a separate record of the snapshot.
+func (c *Client[K, T, PT]) compact() error {
+	if c.journal.walSize == 0 {
+		return nil
+	}
+
+	startedAt := time.Now()
+	err := c.journal.compact(func(emit func(payload []byte) error) error {
+		for _, key := range c.keys {
+			payload, err := json.Marshal(walRecord[K]{Ops: []walOp[K]{{Key: key, Entry: c.entries[key].data}}})
+			if err != nil {
+				return err
+			}
+			if err := emit(payload); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.logger.Debug("WAL compacted", "entriesCnt", len(c.keys), "duration", time.Since(startedAt))
+	return nil
+}
@@ -0,0 +1,362 @@
+package file_storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/file_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/storage_test_suite"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func open(t *testing.T, cfg *file_storage.Config) *file_storage.Client[model.Key, model.CircuitBreakerEntry, *model.CircuitBreakerEntry] {
+	t.Helper()
+	storage, err := file_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	return storage
+}
+
+func TestStorageBehavior(t *testing.T) {
+	storage_test_suite.Run(t, func(t *testing.T) storage_test_suite.Storage {
+		return open(t, &file_storage.Config{Dir: t.TempDir()})
+	})
+}
+
+func TestRecoversFromWAL(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+	_ = storage.AddNewEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1, State: model.StateOpen, LastFailureReason: "timeout"})
+	_ = storage.AddNewEntry(ctx, 2, model.CircuitBreakerEntry{DeviceID: 2})
+	_ = storage.UpsertEntry(ctx, 2, model.CircuitBreakerEntry{DeviceID: 2, ConsecutiveTripsCnt: 5})
+	_ = storage.AddNewEntry(ctx, 3, model.CircuitBreakerEntry{DeviceID: 3})
+	_ = storage.RemoveEntry(ctx, 3)
+
+	// Act
+	recovered := open(t, cfg) // the first client is not shut down, as if the process crashed
+
+	// Assert
+	keys, _ := recovered.GetAllPrimaryKeys(ctx)
+	if len(keys) != 2 || keys[0] != 1 || keys[1] != 2 {
+		t.Fatalf("Expected keys [1 2], but got: %v", keys)
+	}
+	if entry, _ := recovered.GetEntry(ctx, 1); entry.State != model.StateOpen || entry.LastFailureReason != "timeout" || entry.Version != 1 {
+		t.Fatalf("Expected the entry to be recovered, but got: %+v", entry)
+	}
+	if entry, _ := recovered.GetEntry(ctx, 2); entry.ConsecutiveTripsCnt != 5 || entry.Version != 2 {
+		t.Fatalf("Expected the upserted entry of version 2, but got: %+v", entry)
+	}
+}
+
+func TestRecoversFromTruncatedTail(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+	_ = storage.AddNewEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1})
+	_ = storage.AddNewEntry(ctx, 2, model.CircuitBreakerEntry{DeviceID: 2})
+
+	walPath := filepath.Join(cfg.Dir, "entries.wal")
+	info, _ := os.Stat(walPath)
+	if err := os.Truncate(walPath, info.Size()-3); err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+
+	// Act
+	recovered := open(t, cfg)
+	added := recovered.AddNewEntry(ctx, 3, model.CircuitBreakerEntry{DeviceID: 3})
+	reopened := open(t, cfg)
+
+	// Assert
+	if added != nil {
+		t.Fatalf("Expected no errors, but got: %v", added)
+	}
+	keys, _ := reopened.GetAllPrimaryKeys(ctx)
+	if len(keys) != 2 || keys[0] != 1 || keys[1] != 3 {
+		t.Fatalf("Expected the torn record to be dropped and keys [1 3], but got: %v", keys)
+	}
+}
+
+func TestRecoversFromCorruptedTail(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+	_ = storage.AddNewEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1})
+	_ = storage.AddNewEntry(ctx, 2, model.CircuitBreakerEntry{DeviceID: 2})
+
+	walPath := filepath.Join(cfg.Dir, "entries.wal")
+	data, _ := os.ReadFile(walPath)
+	data[len(data)-2] ^= 0xff
+	_ = os.WriteFile(walPath, data, 0o644)
+
+	// Act
+	recovered := open(t, cfg)
+
+	// Assert
+	keys, _ := recovered.GetAllPrimaryKeys(ctx)
+	if len(keys) != 1 || keys[0] != 1 {
+		t.Fatalf("Expected the record failing the checksum to be dropped, but got: %v", keys)
+	}
+}
+
+func entryWithReason(deviceID model.Key, reasonSize int) model.CircuitBreakerEntry {
+	return model.CircuitBreakerEntry{DeviceID: deviceID, LastFailureReason: strings.Repeat("x", reasonSize)}
+}
+
+func TestSplitsLargeBatchAcrossRecords(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	file_storage.SetMaxRecordSize(t, 4096)
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+	var batch []generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry]
+	for i := range 72 {
+		batch = append(batch, generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry]{
+			PrimaryKey: model.Key(i),
+			Entry:      entryWithReason(model.Key(i), 500),
+		})
+	}
+
+	// Act
+	_, batchErr := storage.UpsertEntriesBatch(ctx, batch)
+	upsertErr := storage.UpsertEntry(ctx, 100, model.CircuitBreakerEntry{DeviceID: 100})
+	reopened := open(t, cfg)
+
+	// Assert
+	if batchErr != nil || upsertErr != nil {
+		t.Fatalf("Expected no errors, but got: %v, %v", batchErr, upsertErr)
+	}
+	keys, _ := reopened.GetAllPrimaryKeys(ctx)
+	if len(keys) != 73 {
+		t.Fatalf("Expected all 73 keys to be recovered, but got: %d", len(keys))
+	}
+}
+
+func TestDropsTornBatchEntirely(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	file_storage.SetMaxRecordSize(t, 4096)
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+	_ = storage.AddNewEntry(ctx, 100, model.CircuitBreakerEntry{DeviceID: 100})
+	walPath := filepath.Join(cfg.Dir, "entries.wal")
+	before, _ := os.Stat(walPath)
+	var batch []generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry]
+	for i := range 10 {
+		batch = append(batch, generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry]{
+			PrimaryKey: model.Key(i),
+			Entry:      entryWithReason(model.Key(i), 1000),
+		})
+	}
+	_, _ = storage.UpsertEntriesBatch(ctx, batch)
+	after, _ := os.Stat(walPath)
+	// cut the group of records in the middle, as a crash during the write would
+	if err := os.Truncate(walPath, (before.Size()+after.Size())/2); err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+
+	// Act
+	recovered := open(t, cfg)
+
+	// Assert
+	keys, _ := recovered.GetAllPrimaryKeys(ctx)
+	if len(keys) != 1 || keys[0] != 100 {
+		t.Fatalf("Expected the torn batch to be dropped entirely and keys [100], but got: %v", keys)
+	}
+}
+
+func TestRejectsRecordOverLimit(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	file_storage.SetMaxRecordSize(t, 1024)
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+
+	// Act
+	rejected := storage.UpsertEntry(ctx, 1, entryWithReason(1, 2048))
+	_, getErr := storage.GetEntry(ctx, 1)
+	added := storage.UpsertEntry(ctx, 2, model.CircuitBreakerEntry{DeviceID: 2})
+	reopened := open(t, cfg)
+
+	// Assert
+	if rejected == nil || !errors.Is(getErr, generic_storage.ErrEntryNotFound) {
+		t.Fatalf("Expected the oversized entry to be rejected, but got: %v, %v", rejected, getErr)
+	}
+	if added != nil {
+		t.Fatalf("Expected no errors, but got: %v", added)
+	}
+	keys, _ := reopened.GetAllPrimaryKeys(ctx)
+	if len(keys) != 1 || keys[0] != 2 {
+		t.Fatalf("Expected keys [2], but got: %v", keys)
+	}
+}
+
+func TestFailsRecoveryOnOversizedRecordFollowedByData(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+	_ = storage.UpsertEntry(ctx, 1, entryWithReason(1, 2048))
+	_ = storage.UpsertEntry(ctx, 2, model.CircuitBreakerEntry{DeviceID: 2})
+	file_storage.SetMaxRecordSize(t, 1024)
+
+	// Act
+	_, err := file_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected the recovery to fail, but got no errors")
+	}
+}
+
+func TestFailsRecoveryOnCorruptedRecordFollowedByData(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+	_ = storage.AddNewEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1})
+	_ = storage.AddNewEntry(ctx, 2, model.CircuitBreakerEntry{DeviceID: 2})
+
+	walPath := filepath.Join(cfg.Dir, "entries.wal")
+	data, _ := os.ReadFile(walPath)
+	data[10] ^= 0xff
+	_ = os.WriteFile(walPath, data, 0o644)
+
+	// Act
+	_, err := file_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+
+	// Assert
+	if err == nil {
+		t.Fatalf("Expected the recovery to fail, but got no errors")
+	}
+}
+
+func TestShutdownCompactsWAL(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+	for deviceID := model.Key(1); deviceID <= 10; deviceID++ {
+		_ = storage.UpsertEntry(ctx, deviceID, model.CircuitBreakerEntry{DeviceID: deviceID})
+		_ = storage.UpsertEntry(ctx, deviceID, model.CircuitBreakerEntry{DeviceID: deviceID, ConsecutiveTripsCnt: int(deviceID)})
+	}
+
+	// Act
+	err := storage.Shutdown(ctx)
+	afterShutdown := storage.IsAlive(ctx)
+	recovered := open(t, cfg)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	if !errors.Is(afterShutdown, generic_storage.ErrNotInitialized) {
+		t.Fatalf("Expected %v, but got: %v", generic_storage.ErrNotInitialized, afterShutdown)
+	}
+	if info, _ := os.Stat(filepath.Join(cfg.Dir, "entries.wal")); info.Size() != 0 {
+		t.Fatalf("Expected the WAL to be empty, but got: %d bytes", info.Size())
+	}
+	entries, _ := recovered.GetAllEntries(ctx)
+	if len(entries) != 10 || entries[9].ConsecutiveTripsCnt != 10 || entries[9].Version != 2 {
+		t.Fatalf("Expected 10 entries recovered from the snapshot, but got: %+v", entries)
+	}
+}
+
+func TestCompactsPeriodically(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir(), SnapshotInterval: 10 * time.Millisecond}
+	storage := open(t, cfg)
+	defer storage.Shutdown(ctx)
+	_ = storage.AddNewEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1})
+
+	// Act
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if info, _ := os.Stat(filepath.Join(cfg.Dir, "entries.snapshot")); info != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the snapshot to be written, but got none")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = storage.AddNewEntry(ctx, 2, model.CircuitBreakerEntry{DeviceID: 2})
+	recovered := open(t, cfg)
+
+	// Assert
+	keys, _ := recovered.GetAllPrimaryKeys(ctx)
+	if len(keys) != 2 {
+		t.Fatalf("Expected the entries from both the snapshot and the WAL, but got: %v", keys)
+	}
+}
+
+func TestBatchResultsAndPagination(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage := open(t, cfg)
+	_ = storage.AddNewEntry(ctx, 5, model.CircuitBreakerEntry{DeviceID: 5})
+	batch := []generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry]{
+		{PrimaryKey: 3, Entry: model.CircuitBreakerEntry{DeviceID: 3}},
+		{PrimaryKey: 5, Entry: model.CircuitBreakerEntry{DeviceID: 5}},
+		{PrimaryKey: 1, Entry: model.CircuitBreakerEntry{DeviceID: 1}},
+	}
+
+	// Act
+	results, err := storage.AddNewEntriesBatch(ctx, batch)
+	recovered := open(t, cfg)
+	lastKey := model.Key(1)
+	page, _ := recovered.GetAllEntriesPaginated(ctx, &lastKey, 1)
+
+	// Assert
+	if err != nil || results[0] != nil || !errors.Is(results[1], generic_storage.ErrEntryAlreadyExists) || results[2] != nil {
+		t.Fatalf("Expected only the existing key to fail, but got: %v, %v", results, err)
+	}
+	if len(page) != 1 || page[0].DeviceID != 3 {
+		t.Fatalf("Expected the page with device 3, but got: %+v", page)
+	}
+}
+
+func TestHistoryRecovers(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	history, _ := file_storage.NewHistory(cfg, "history", logger)
+	_ = history.AppendRecord(ctx, 1, model.Transition{From: model.StateClosed, To: model.StateOpen})
+	_ = history.AppendRecord(ctx, 1, model.Transition{From: model.StateOpen, To: model.StateHalfOpen})
+	_ = history.AppendRecord(ctx, 2, model.Transition{From: model.StateClosed, To: model.StateOpen})
+	_ = history.Shutdown(ctx)
+
+	reopened, _ := file_storage.NewHistory(cfg, "history", logger)
+	_ = reopened.RemoveRecords(ctx, 2)
+
+	// Act
+	recovered, err := file_storage.NewHistory(cfg, "history", logger)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	records, _ := recovered.GetRecordsPaginated(ctx, 1, 0, 10)
+	if len(records) != 2 || records[0].To != model.StateHalfOpen {
+		t.Fatalf("Expected 2 records newest first, but got: %+v", records)
+	}
+	if count, _ := recovered.CountRecords(ctx, 2); count != 0 {
+		t.Fatalf("Expected the removed records to stay removed, but got: %d", count)
+	}
+}
@@ -0,0 +1,25 @@
+package file_storage
+
+import (
+	"fmt"
+	"time"
+)
+
+type Config struct {
+	// Dir keeps the snapshot and the WAL of each storage, it is created if missing.
+	Dir string `yaml:"dir"`
+	// SnapshotInterval is how often the WAL is compacted into the snapshot, it is compacted on Shutdown only if zero.
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"`
+}
+
+func (c *Config) Validate() error {
+	if c.Dir == "" {
+		return fmt.Errorf("missed Dir config param")
+	}
+
+	if c.SnapshotInterval < 0 {
+		return fmt.Errorf("SnapshotInterval cannot be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+package file_storage
+
+import "testing"
+
+// SetMaxRecordSize lowers the record size limit for the duration of the test.
+func SetMaxRecordSize(t *testing.T, size int) {
+	previous := maxRecordSize
+	maxRecordSize = size
+	t.Cleanup(func() { maxRecordSize = previous })
+}
@@ -0,0 +1,267 @@
+package file_storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_history_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// HistoryClient is the file-backed generic_history_storage.HistoryStorageClient of the state transitions.
+// It keeps the records in memory the same way Client does, see Client.
+type HistoryClient struct {
+	logger *slog.Logger
+
+	mu      sync.RWMutex
+	journal *journal // nil once the client is shut down
+	records map[model.Key][]model.Transition
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// historyRecord is the payload of the WAL and snapshot records, the records are appended to the ones of the key,
+// all records of the key are removed if there are none.
+type historyRecord struct {
+	Key     model.Key          `json:"key"`
+	Records []model.Transition `json:"records,omitempty"`
+}
+
+// NewHistory opens the history kept in the files named after name in cfg.Dir, the previous records are recovered.
+func NewHistory(cfg *Config, name string, logger *slog.Logger) (*HistoryClient, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("configuration cannot be nil")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("name cannot be empty")
+	}
+
+	if logger == nil {
+		return nil, fmt.Errorf("logger cannot be nil")
+	}
+
+	c := &HistoryClient{
+		logger:  logger.With("component", "file-history-storage", "name", name),
+		records: make(map[model.Key][]model.Transition),
+	}
+
+	journal, err := openJournal(cfg.Dir, name, c.logger, c.replay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	c.journal = journal
+	c.logger.Info("History loaded", "keysCnt", len(c.records), "walSize", journal.walSize)
+
+	if cfg.SnapshotInterval > 0 {
+		c.stop = make(chan struct{})
+		c.done = make(chan struct{})
+		go runEvery(cfg.SnapshotInterval, c.stop, c.done, c.compactPeriodically)
+	}
+
+	return c, nil
+}
+
+// Shutdown compacts the WAL into the snapshot and closes the files, the client cannot be used afterwards.
+func (c *HistoryClient) Shutdown(ctx context.Context) error {
+	c.logger.Debug("Shutdown called")
+
+	c.mu.Lock()
+	if c.journal == nil {
+		c.mu.Unlock()
+		return generic_history_storage.ErrNotInitialized
+	}
+
+	compactErr := c.compact()
+	closeErr := c.journal.close()
+	c.journal = nil
+	c.mu.Unlock()
+
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+	}
+
+	if compactErr != nil {
+		return fmt.Errorf("failed to compact: %w", compactErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close WAL: %w", closeErr)
+	}
+	return nil
+}
+
+// IsAlive checks if the history storage client is alive.
+func (c *HistoryClient) IsAlive(ctx context.Context) error {
+	c.logger.Debug("IsAlive called")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.journal == nil {
+		return generic_history_storage.ErrNotInitialized
+	}
+	return nil
+}
+
+// AppendRecord stores the transition as the newest record of the key.
+func (c *HistoryClient) AppendRecord(ctx context.Context, primaryKey model.Key, record model.Transition) error {
+	c.logger.Debug("AppendRecord called", "primaryKey", primaryKey, "record", record)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return generic_history_storage.ErrNotInitialized
+	}
+
+	if err := c.commit(historyRecord{Key: primaryKey, Records: []model.Transition{record}}); err != nil {
+		return err
+	}
+	c.records[primaryKey] = append(c.records[primaryKey], record)
+	return nil
+}
+
+// GetRecordsPaginated retrieves up to pageSize records of the key newest first, skipping offset newest records.
+func (c *HistoryClient) GetRecordsPaginated(ctx context.Context, primaryKey model.Key, offset int, pageSize int) ([]model.Transition, error) {
+	c.logger.Debug("GetRecordsPaginated called", "primaryKey", primaryKey, "offset", offset, "pageSize", pageSize)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.journal == nil {
+		return nil, generic_history_storage.ErrNotInitialized
+	}
+
+	stored := c.records[primaryKey]
+	offset = max(offset, 0)
+	records := make([]model.Transition, 0, max(min(pageSize, len(stored)-offset), 0))
+	for i := len(stored) - 1 - offset; i >= 0 && len(records) < pageSize; i-- {
+		records = append(records, stored[i])
+	}
+	return records, nil
+}
+
+// CountRecords returns the number of records of the key.
+func (c *HistoryClient) CountRecords(ctx context.Context, primaryKey model.Key) (int, error) {
+	c.logger.Debug("CountRecords called", "primaryKey", primaryKey)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.journal == nil {
+		return 0, generic_history_storage.ErrNotInitialized
+	}
+	return len(c.records[primaryKey]), nil
+}
+
+// RemoveRecords removes all records of the key.
+func (c *HistoryClient) RemoveRecords(ctx context.Context, primaryKey model.Key) error {
+	c.logger.Debug("RemoveRecords called", "primaryKey", primaryKey)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return generic_history_storage.ErrNotInitialized
+	}
+
+	if _, exists := c.records[primaryKey]; !exists {
+		return nil
+	}
+	if err := c.commit(historyRecord{Key: primaryKey}); err != nil {
+		return err
+	}
+	delete(c.records, primaryKey)
+	return nil
+}
+
+// The helpers below should be called with c.mu held.
+
+func (c *HistoryClient) commit(record historyRecord) error {
+	payload, err := json.Marshal(record)
+	if err == nil {
+		err = c.journal.append(payload)
+	}
+	if err != nil {
+		c.logger.Error("Failed to commit write", "primaryKey", record.Key, "error", err)
+		return fmt.Errorf("failed to commit write: %w", err)
+	}
+	return nil
+}
+
+func (c *HistoryClient) replay(payload []byte) error {
+	var record historyRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return fmt.Errorf("failed to decode record: %w", err)
+	}
+
+	if len(record.Records) == 0 {
+		delete(c.records, record.Key)
+		return nil
+	}
+	c.records[record.Key] = append(c.records[record.Key], record.Records...)
+	return nil
+}
+
+func (c *HistoryClient) compactPeriodically() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return
+	}
+	if err := c.compact(); err != nil {
+		c.logger.Error("Failed to compact WAL", "error", err)
+	}
+}
+
+// compact writes the records of each key as a separate record of the snapshot.
+func (c *HistoryClient) compact() error {
+	if c.journal.walSize == 0 {
+		return nil
+	}
+
+	keys := make([]model.Key, 0, len(c.records))
+	for key := range c.records {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return c.journal.compact(func(emit func(payload []byte) error) error {
+		for _, key := range keys {
+			if err := emitRecords(emit, key, c.records[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// emitRecords writes the records of the key as a single snapshot record, or splits them in halves
+// until each part fits into maxRecordSize, the replay appends the parts in order.
+func emitRecords(emit func(payload []byte) error, key model.Key, records []model.Transition) error {
+	payload, err := json.Marshal(historyRecord{Key: key, Records: records})
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > maxRecordSize && len(records) > 1 {
+		half := len(records) / 2
+		if err := emitRecords(emit, key, records[:half]); err != nil {
+			return err
+		}
+		return emitRecords(emit, key, records[half:])
+	}
+
+	return emit(payload)
+}
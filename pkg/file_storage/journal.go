@@ -0,0 +1,333 @@
+package file_storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Both the snapshot and the WAL are sequences of records framed as
+// [payload length: 4 bytes][CRC-32C of the payload: 4 bytes][payload], the integers are big-endian.
+// The top bit of the length marks a record continued by the next one, such records form a group
+// which is appended with a single write and recovered either entirely or not at all.
+// The WAL is appended and fsynced on each write, so a crash may leave only its last group cut short,
+// which is truncated on recovery. A damaged record followed by more data fails the recovery instead,
+// the acknowledged writes behind it would be lost otherwise.
+// The snapshot is written to a temporary file and renamed, so it is either complete or missing.
+
+const recordHeaderSize = 8
+
+const continuedFlag = 1 << 31
+
+// maxRecordSize limits the payload of a single record, the larger writes are rejected before they reach the file.
+// It is a variable, so the tests can lower it.
+var maxRecordSize = 64 << 20
+
+// errRecordTooLarge is returned when the payload of a record exceeds maxRecordSize.
+var errRecordTooLarge = errors.New("record is too large")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// journal is the snapshot and the WAL of a single storage.
+type journal struct {
+	logger       *slog.Logger
+	dir          string
+	snapshotPath string
+	wal          *os.File
+	walSize      int64
+}
+
+// openJournal replays the snapshot and then the WAL into apply and opens the WAL for appending.
+func openJournal(dir string, name string, logger *slog.Logger, apply func(payload []byte) error) (*journal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	j := &journal{
+		logger:       logger,
+		dir:          dir,
+		snapshotPath: filepath.Join(dir, name+".snapshot"),
+	}
+
+	if err := j.replaySnapshot(apply); err != nil {
+		return nil, err
+	}
+
+	walPath := filepath.Join(dir, name+".wal")
+	wal, err := os.OpenFile(walPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+
+	if err := j.recoverWAL(wal, apply); err != nil {
+		_ = wal.Close()
+		return nil, err
+	}
+	j.wal = wal
+
+	return j, nil
+}
+
+func (j *journal) replaySnapshot(apply func(payload []byte) error) error {
+	snapshot, err := os.Open(j.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer snapshot.Close()
+
+	info, err := snapshot.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat snapshot: %w", err)
+	}
+
+	end, err := readRecords(snapshot, info.Size(), apply)
+	if err != nil {
+		return fmt.Errorf("failed to replay snapshot %s: %w", j.snapshotPath, err)
+	}
+	if end != info.Size() {
+		return fmt.Errorf("snapshot %s is corrupted at offset %d", j.snapshotPath, end)
+	}
+
+	return nil
+}
+
+func (j *journal) recoverWAL(wal *os.File, apply func(payload []byte) error) error {
+	info, err := wal.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat WAL: %w", err)
+	}
+
+	end, err := readRecords(wal, info.Size(), apply)
+	if err != nil {
+		return fmt.Errorf("failed to replay WAL %s: %w", wal.Name(), err)
+	}
+	if end < info.Size() {
+		j.logger.Warn("Truncating the torn tail of the WAL", "path", wal.Name(), "size", info.Size(), "validSize", end)
+		if err := wal.Truncate(end); err != nil {
+			return fmt.Errorf("failed to truncate WAL: %w", err)
+		}
+		if err := wal.Sync(); err != nil {
+			return fmt.Errorf("failed to sync WAL: %w", err)
+		}
+	}
+
+	if _, err := wal.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek WAL: %w", err)
+	}
+	j.walSize = end
+
+	return nil
+}
+
+// append writes the payloads to the WAL as a single group of records, the group is durable once append returns.
+// Fails with errRecordTooLarge without writing anything if any payload exceeds maxRecordSize.
+func (j *journal) append(payloads ...[]byte) error {
+	var group []byte
+	for i, payload := range payloads {
+		if len(payload) > maxRecordSize {
+			return fmt.Errorf("%w: %d bytes, at most %d are allowed", errRecordTooLarge, len(payload), maxRecordSize)
+		}
+		group = append(group, encodeRecord(payload, i < len(payloads)-1)...)
+	}
+
+	if _, err := j.wal.Write(group); err != nil {
+		// Drop the partially written group, the next ones would be lost behind it on recovery otherwise.
+		j.rewind()
+		return fmt.Errorf("failed to write WAL: %w", err)
+	}
+
+	if err := j.wal.Sync(); err != nil {
+		j.rewind()
+		return fmt.Errorf("failed to sync WAL: %w", err)
+	}
+
+	j.walSize += int64(len(group))
+	return nil
+}
+
+func (j *journal) rewind() {
+	if err := j.wal.Truncate(j.walSize); err != nil {
+		j.logger.Error("Failed to truncate WAL", "error", err)
+	}
+	if _, err := j.wal.Seek(j.walSize, io.SeekStart); err != nil {
+		j.logger.Error("Failed to seek WAL", "error", err)
+	}
+}
+
+// compact replaces the snapshot with the records passed to emit by write and empties the WAL.
+// The caller must make sure the state is not changed until compact returns.
+func (j *journal) compact(write func(emit func(payload []byte) error) error) error {
+	tmpPath := j.snapshotPath + ".tmp"
+	if err := writeSnapshot(tmpPath, write); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, j.snapshotPath); err != nil {
+		return fmt.Errorf("failed to rename snapshot: %w", err)
+	}
+	if err := syncDir(j.dir); err != nil {
+		return err
+	}
+
+	// NOTE (maksym): a crash before the WAL is emptied replays it over the new snapshot,
+	// which is harmless since the records hold the whole entries rather than the changes
+	if err := j.wal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate WAL: %w", err)
+	}
+	if _, err := j.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek WAL: %w", err)
+	}
+	if err := j.wal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync WAL: %w", err)
+	}
+	j.walSize = 0
+
+	return nil
+}
+
+func (j *journal) close() error {
+	return j.wal.Close()
+}
+
+func writeSnapshot(path string, write func(emit func(payload []byte) error) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	err = write(func(payload []byte) error {
+		if len(payload) > maxRecordSize {
+			return fmt.Errorf("%w: %d bytes, at most %d are allowed", errRecordTooLarge, len(payload), maxRecordSize)
+		}
+		_, err := buffered.Write(encodeRecord(payload, false))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+
+	return file.Close()
+}
+
+// syncDir makes the rename durable.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer dir.Close()
+
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+func encodeRecord(payload []byte, continued bool) []byte {
+	length := uint32(len(payload))
+	if continued {
+		length |= continuedFlag
+	}
+
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], length)
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(payload, crcTable))
+	copy(record[recordHeaderSize:], payload)
+	return record
+}
+
+// readRecords passes the payloads of the intact groups of records to fn and returns the offset right after the last
+// of them, size is the size of the file. The group which is cut short or fails the checksum at the end of the file is
+// the torn tail and is skipped, a damaged record followed by more data fails with an error.
+func readRecords(r io.Reader, size int64, fn func(payload []byte) error) (int64, error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, recordHeaderSize)
+
+	var offset, position int64
+	var group [][]byte
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return offset, ignoreTornRecord(err)
+		}
+
+		length := binary.BigEndian.Uint32(header[0:4])
+		payloadSize := int64(length &^ continuedFlag)
+		end := position + recordHeaderSize + payloadSize
+		if end > size {
+			return offset, nil
+		}
+		if payloadSize > int64(maxRecordSize) {
+			return offset, fmt.Errorf("record at offset %d is %d bytes, at most %d are allowed", position, payloadSize, maxRecordSize)
+		}
+
+		payload := make([]byte, payloadSize)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return offset, ignoreTornRecord(err)
+		}
+
+		if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+			if end == size {
+				return offset, nil
+			}
+			return offset, fmt.Errorf("record at offset %d fails the checksum", position)
+		}
+		position = end
+
+		group = append(group, payload)
+		if length&continuedFlag != 0 {
+			continue
+		}
+
+		for _, payload := range group {
+			if err := fn(payload); err != nil {
+				return offset, err
+			}
+		}
+		group = group[:0]
+		offset = position
+	}
+}
+
+func ignoreTornRecord(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	return err
+}
+
+// runEvery calls fn every interval until stop is closed, done is closed on return.
+func runEvery(interval time.Duration, stop <-chan struct{}, done chan<- struct{}, fn func()) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package generic_history_storage
+
+import (
+	"context"
+)
+
+// NOTE: K for primary key, T for history record.
+type HistoryStorageClient[K any, T any] interface {
+	Shutdown(ctx context.Context) error
+	IsAlive(ctx context.Context) error
+
+	// records are append-only, they are never updated once stored
+	AppendRecord(ctx context.Context, primaryKey K, record T) error
+	// records are returned newest first, offset is the number of the newest records to skip
+	GetRecordsPaginated(ctx context.Context, primaryKey K, offset int, pageSize int) ([]T, error)
+	CountRecords(ctx context.Context, primaryKey K) (int, error)
+	RemoveRecords(ctx context.Context, primaryKey K) error
+}
@@ -0,0 +1,7 @@
+package generic_history_storage
+
+import (
+	"errors"
+)
+
+var ErrNotInitialized = errors.New("history storage: not initialized")
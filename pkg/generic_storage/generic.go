@@ -4,6 +4,13 @@ import (
 	"context"
 )
 
+// BatchEntry is a single entry of the batch write.
+type BatchEntry[K any, T any] struct {
+	PrimaryKey      K
+	ExpectedVersion uint64 // Used by CompareAndSwapBatch only
+	Entry           T
+}
+
 // NOTE: K for primary key, T for full entry.
 type StorageClient[K any, T any] interface {
 	Shutdown(ctx context.Context) error
@@ -12,13 +19,23 @@ type StorageClient[K any, T any] interface {
 	// NOTE (maksym): updates attribute, creates attribute if it not exists
 	UpsertEntry(ctx context.Context, primaryKey K, entry T) error
 	AddNewEntry(ctx context.Context, primaryKey K, entry T) error
+	// every write increments the version of the entry, CompareAndSwap stores the entry only if
+	// the stored version is still expectedVersion and returns ErrConflict otherwise. Zero expectedVersion means
+	// the entry should not exist yet.
+	CompareAndSwap(ctx context.Context, primaryKey K, expectedVersion uint64, entry T) error
 	RemoveEntry(ctx context.Context, primaryKey K) error
 	GetEntry(ctx context.Context, primaryKey K) (T, error)
 	GetAllEntries(ctx context.Context) ([]T, error)
-	GetAllEntriesPaginated(ctx context.Context, lastPrimaryKey K, pageSize int) ([]T, error)
+	// the entries are ordered by the primary key, the page starts right after lastPrimaryKey,
+	// which may be missing in storage already, nil lastPrimaryKey means the first page
+	GetAllEntriesPaginated(ctx context.Context, lastPrimaryKey *K, pageSize int) ([]T, error)
 	GetAllPrimaryKeys(ctx context.Context) ([]K, error)
 
-	// TODO: implement RemoveEntriesbatch(), if necessary to not establish DB connection on each entry
-	// TODO: implement AddNewEntriesbatch(), if necessary to not establish DB connection on each entry
-	// TODO: implement UpsertEntriesbatch(), if necessary to not establish DB connection on each entry
+	// the batch methods make the same changes as the single entry ones without establishing
+	// DB connection on each entry. The result of each entry is returned in the order of the input, nil on success,
+	// the error is returned only if the whole batch failed.
+	UpsertEntriesBatch(ctx context.Context, entries []BatchEntry[K, T]) ([]error, error)
+	AddNewEntriesBatch(ctx context.Context, entries []BatchEntry[K, T]) ([]error, error)
+	CompareAndSwapBatch(ctx context.Context, entries []BatchEntry[K, T]) ([]error, error)
+	RemoveEntriesBatch(ctx context.Context, primaryKeys []K) ([]error, error)
 }
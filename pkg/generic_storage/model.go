@@ -7,3 +7,4 @@ import (
 var ErrNotInitialized = errors.New("storage: not initialized")
 var ErrEntryNotFound = errors.New("storage: entry not found")
 var ErrEntryAlreadyExists = errors.New("storage: entry already exists")
+var ErrConflict = errors.New("storage: entry was changed concurrently")
@@ -2,15 +2,14 @@ package map_test_storage
 
 import (
 	"context"
-	"errors"
+	"slices"
 	"sync"
 
 	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
-	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
 )
 
 // Shutdown gracefully shuts down the storage client.
-func (c *Client) Shutdown(ctx context.Context) error {
+func (c *Client[K, T, PT]) Shutdown(ctx context.Context) error {
 	if !c.initialized {
 		return generic_storage.ErrNotInitialized
 	}
@@ -20,7 +19,7 @@ func (c *Client) Shutdown(ctx context.Context) error {
 }
 
 // IsAlive checks if the storage client is alive.
-func (c *Client) IsAlive(ctx context.Context) error {
+func (c *Client[K, T, PT]) IsAlive(ctx context.Context) error {
 	if !c.initialized {
 		return generic_storage.ErrNotInitialized
 	}
@@ -29,37 +28,168 @@ func (c *Client) IsAlive(ctx context.Context) error {
 }
 
 // UpsertEntry inserts or updates an entry in the storage.
-func (c *Client) UpsertEntry(ctx context.Context, primaryKey model.Key, entry model.CircuitBreakerEntry) error {
+func (c *Client[K, T, PT]) UpsertEntry(ctx context.Context, primaryKey K, entry T) error {
 	if !c.initialized {
 		return generic_storage.ErrNotInitialized
 	}
 	c.logger.Debug("UpsertEntry called", "primaryKey", primaryKey, "entry", entry)
-	c.registry.Store(primaryKey, entry)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.upsertEntry(primaryKey, entry)
 	return nil
 }
 
 // AddNewEntry adds a new entry to the storage. Fails if the key already exists.
-func (c *Client) AddNewEntry(ctx context.Context, primaryKey model.Key, entry model.CircuitBreakerEntry) error {
+func (c *Client[K, T, PT]) AddNewEntry(ctx context.Context, primaryKey K, entry T) error {
 	if !c.initialized {
 		return generic_storage.ErrNotInitialized
 	}
 	c.logger.Debug("AddNewEntry called", "primaryKey", primaryKey, "entry", entry)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.addNewEntry(primaryKey, entry)
+}
+
+// CompareAndSwap updates the entry only if its stored version matches expectedVersion,
+// zero expectedVersion adds a new entry. Fails with generic_storage.ErrConflict otherwise.
+func (c *Client[K, T, PT]) CompareAndSwap(ctx context.Context, primaryKey K, expectedVersion uint64, entry T) error {
+	if !c.initialized {
+		return generic_storage.ErrNotInitialized
+	}
+	c.logger.Debug("CompareAndSwap called", "primaryKey", primaryKey, "expectedVersion", expectedVersion, "entry", entry)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.compareAndSwap(primaryKey, expectedVersion, entry)
+}
+
+func (c *Client[K, T, PT]) RemoveEntry(ctx context.Context, primaryKey K) error {
+	if !c.initialized {
+		return generic_storage.ErrNotInitialized
+	}
+	c.logger.Debug("RemoveEntry called", "primaryKey", primaryKey)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.removeEntry(primaryKey)
+}
+
+// UpsertEntriesBatch inserts or updates entries in the storage.
+func (c *Client[K, T, PT]) UpsertEntriesBatch(ctx context.Context, entries []generic_storage.BatchEntry[K, T]) ([]error, error) {
+	if !c.initialized {
+		return nil, generic_storage.ErrNotInitialized
+	}
+	c.logger.Debug("UpsertEntriesBatch called", "entriesCnt", len(entries))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range entries {
+		c.upsertEntry(item.PrimaryKey, item.Entry)
+	}
+	return make([]error, len(entries)), nil
+}
+
+// AddNewEntriesBatch adds new entries to the storage, the ones which keys already exist are skipped.
+func (c *Client[K, T, PT]) AddNewEntriesBatch(ctx context.Context, entries []generic_storage.BatchEntry[K, T]) ([]error, error) {
+	if !c.initialized {
+		return nil, generic_storage.ErrNotInitialized
+	}
+	c.logger.Debug("AddNewEntriesBatch called", "entriesCnt", len(entries))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := make([]error, len(entries))
+	for i, item := range entries {
+		results[i] = c.addNewEntry(item.PrimaryKey, item.Entry)
+	}
+	return results, nil
+}
+
+// CompareAndSwapBatch updates entries which stored versions match their ExpectedVersion, see CompareAndSwap.
+func (c *Client[K, T, PT]) CompareAndSwapBatch(ctx context.Context, entries []generic_storage.BatchEntry[K, T]) ([]error, error) {
+	if !c.initialized {
+		return nil, generic_storage.ErrNotInitialized
+	}
+	c.logger.Debug("CompareAndSwapBatch called", "entriesCnt", len(entries))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := make([]error, len(entries))
+	for i, item := range entries {
+		results[i] = c.compareAndSwap(item.PrimaryKey, item.ExpectedVersion, item.Entry)
+	}
+	return results, nil
+}
+
+// RemoveEntriesBatch removes entries from the storage, the missing ones fail with generic_storage.ErrEntryNotFound.
+func (c *Client[K, T, PT]) RemoveEntriesBatch(ctx context.Context, primaryKeys []K) ([]error, error) {
+	if !c.initialized {
+		return nil, generic_storage.ErrNotInitialized
+	}
+	c.logger.Debug("RemoveEntriesBatch called", "keysCnt", len(primaryKeys))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := make([]error, len(primaryKeys))
+	for i, primaryKey := range primaryKeys {
+		results[i] = c.removeEntry(primaryKey)
+	}
+	return results, nil
+}
+
+// The helpers below should be called with c.mu held.
+
+func (c *Client[K, T, PT]) upsertEntry(primaryKey K, entry T) {
+	PT(&entry).SetVersion(c.version(primaryKey) + 1)
+	c.registry.Store(primaryKey, clone[T, PT](entry))
+}
+
+func (c *Client[K, T, PT]) addNewEntry(primaryKey K, entry T) error {
 	if _, exists := c.registry.Load(primaryKey); exists {
-		err := errors.New("entry already exists")
+		err := generic_storage.ErrEntryAlreadyExists
 		c.logger.Debug("AddNewEntry failed", "primaryKey", primaryKey, "error", err)
 		return err
 	}
-	c.registry.Store(primaryKey, entry)
+	PT(&entry).SetVersion(1)
+	c.registry.Store(primaryKey, clone[T, PT](entry))
 	return nil
 }
 
-func (c *Client) RemoveEntry(ctx context.Context, primaryKey model.Key) error {
-	if !c.initialized {
-		return generic_storage.ErrNotInitialized
+func (c *Client[K, T, PT]) compareAndSwap(primaryKey K, expectedVersion uint64, entry T) error {
+	version := c.version(primaryKey)
+	if version != expectedVersion {
+		err := generic_storage.ErrConflict
+		c.logger.Debug("CompareAndSwap failed", "primaryKey", primaryKey, "version", version, "error", err)
+		return err
 	}
-	c.logger.Debug("RemoveEntry called", "primaryKey", primaryKey)
+	PT(&entry).SetVersion(expectedVersion + 1)
+	c.registry.Store(primaryKey, clone[T, PT](entry))
+	return nil
+}
+
+// version returns the version of the stored entry, zero if there is none.
+func (c *Client[K, T, PT]) version(primaryKey K) uint64 {
+	stored, exists := c.registry.Load(primaryKey)
+	if !exists {
+		return 0
+	}
+	entry := stored.(T)
+	return PT(&entry).GetVersion()
+}
+
+func (c *Client[K, T, PT]) removeEntry(primaryKey K) error {
 	if _, exists := c.registry.Load(primaryKey); !exists {
-		err := errors.New("entry does not exist")
+		err := generic_storage.ErrEntryNotFound
 		c.logger.Debug("RemoveEntry failed", "primaryKey", primaryKey, "error", err)
 		return err
 	}
@@ -67,66 +197,75 @@ func (c *Client) RemoveEntry(ctx context.Context, primaryKey model.Key) error {
 	return nil
 }
 
-func (c *Client) GetEntry(ctx context.Context, primaryKey model.Key) (model.CircuitBreakerEntry, error) {
+func (c *Client[K, T, PT]) GetEntry(ctx context.Context, primaryKey K) (T, error) {
+	var zero T
 	if !c.initialized {
-		return model.CircuitBreakerEntry{}, generic_storage.ErrNotInitialized
+		return zero, generic_storage.ErrNotInitialized
 	}
 	c.logger.Debug("GetEntry called", "primaryKey", primaryKey)
 	entry, exists := c.registry.Load(primaryKey)
 	if !exists {
-		err := errors.New("entry does not exist")
+		err := generic_storage.ErrEntryNotFound
 		c.logger.Debug("GetEntry failed", "primaryKey", primaryKey, "error", err)
-		return model.CircuitBreakerEntry{}, err
+		return zero, err
 	}
-	return entry.(model.CircuitBreakerEntry), nil
+	return clone[T, PT](entry.(T)), nil
 }
 
 // GetAllEntries retrieves all entries in the storage.
-func (c *Client) GetAllEntries(ctx context.Context) ([]model.CircuitBreakerEntry, error) {
+func (c *Client[K, T, PT]) GetAllEntries(ctx context.Context) ([]T, error) {
 	if !c.initialized {
 		return nil, generic_storage.ErrNotInitialized
 	}
 	c.logger.Debug("GetAllEntries called")
-	var entries []model.CircuitBreakerEntry
+	var entries []T
 	c.registry.Range(func(_, value interface{}) bool {
-		entries = append(entries, value.(model.CircuitBreakerEntry))
+		entries = append(entries, clone[T, PT](value.(T)))
 		return true
 	})
 	return entries, nil
 }
 
-// GetAllEntriesPaginated retrieves a paginated list of entries starting from the lastPrimaryKey.
-func (c *Client) GetAllEntriesPaginated(ctx context.Context, lastPrimaryKey model.Key, pageSize int) ([]model.CircuitBreakerEntry, error) {
+// GetAllEntriesPaginated retrieves a page of entries ordered by the primary key, starting right after the lastPrimaryKey.
+// The keys of sync.Map are not ordered, so they are scanned and sorted on each call, which is fine for the tests.
+func (c *Client[K, T, PT]) GetAllEntriesPaginated(ctx context.Context, lastPrimaryKey *K, pageSize int) ([]T, error) {
 	if !c.initialized {
 		return nil, generic_storage.ErrNotInitialized
 	}
 	c.logger.Debug("GetAllEntriesPaginated called", "lastPrimaryKey", lastPrimaryKey, "pageSize", pageSize)
-	var entries []model.CircuitBreakerEntry
-	var foundLastKey bool
-
-	c.registry.Range(func(key, value interface{}) bool {
-		if foundLastKey || key.(model.Key) == lastPrimaryKey {
-			foundLastKey = true
-			entries = append(entries, value.(model.CircuitBreakerEntry))
-			if len(entries) >= pageSize {
-				return false
-			}
+
+	var keys []K
+	c.registry.Range(func(key, _ interface{}) bool {
+		if lastPrimaryKey == nil || key.(K) > *lastPrimaryKey {
+			keys = append(keys, key.(K))
 		}
 		return true
 	})
+	slices.Sort(keys)
+
+	entries := make([]T, 0, min(pageSize, len(keys)))
+	for _, key := range keys {
+		if len(entries) >= pageSize {
+			break
+		}
+		// the entry could be removed since the keys were collected
+		if value, exists := c.registry.Load(key); exists {
+			entries = append(entries, clone[T, PT](value.(T)))
+		}
+	}
 
 	return entries, nil
 }
 
 // GetAllPrimaryKeys retrieves all primary keys in the storage.
-func (c *Client) GetAllPrimaryKeys(ctx context.Context) ([]model.Key, error) {
+func (c *Client[K, T, PT]) GetAllPrimaryKeys(ctx context.Context) ([]K, error) {
 	if !c.initialized {
 		return nil, generic_storage.ErrNotInitialized
 	}
 	c.logger.Debug("GetAllPrimaryKeys called")
-	var keys []model.Key
+	var keys []K
 	c.registry.Range(func(key, _ interface{}) bool {
-		keys = append(keys, key.(model.Key))
+		keys = append(keys, key.(K))
 		return true
 	})
 	return keys, nil
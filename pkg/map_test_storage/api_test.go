@@ -0,0 +1,47 @@
+package map_test_storage_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/map_test_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/storage_test_suite"
+)
+
+func TestStorageBehavior(t *testing.T) {
+	storage_test_suite.Run(t, func(t *testing.T) storage_test_suite.Storage {
+		storage, _ := map_test_storage.New[model.Key, model.CircuitBreakerEntry](slog.New(slog.NewTextHandler(io.Discard, nil)))
+		return storage
+	})
+}
+
+func TestProfilesPaginatedOrderedByKey(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	storage, _ := map_test_storage.New[string, model.Profile](slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for _, name := range []string{"d", "b", "e", "a", "c"} {
+		_ = storage.AddNewEntry(ctx, name, model.Profile{Name: name})
+	}
+	removed := "b"
+	_ = storage.RemoveEntry(ctx, removed)
+
+	// Act
+	first, _ := storage.GetAllEntriesPaginated(ctx, nil, 2)
+	second, _ := storage.GetAllEntriesPaginated(ctx, &removed, 2)
+	last, _ := storage.GetAllEntriesPaginated(ctx, &second[1].Name, 2)
+
+	// Assert
+	names := func(profiles []model.Profile) string {
+		var s string
+		for _, p := range profiles {
+			s += p.Name
+		}
+		return s
+	}
+	if names(first) != "ac" || names(second) != "cd" || names(last) != "e" {
+		t.Fatalf("Expected pages ac, cd and e, but got: %s, %s, %s", names(first), names(second), names(last))
+	}
+}
@@ -1,28 +1,38 @@
 package map_test_storage
 
 import (
+	"cmp"
 	"log/slog"
 	"sync"
 )
 
 // NOTE (maksym): this dummy storage should be used in unit tests only
 
-var client Client
+// versioned is the pointer to the entry which version is managed by the storage, see model.CircuitBreakerEntry.
+// The entries are cloned on each read and write, so the stored ones are never shared with the callers.
+type versioned[T any] interface {
+	*T
+	GetVersion() uint64
+	SetVersion(version uint64)
+	Clone() T
+}
 
-type Client struct {
+// Client keeps the entries in memory, K is the primary key and T is the entry.
+type Client[K cmp.Ordered, T any, PT versioned[T]] struct {
 	logger      *slog.Logger
 	registry    sync.Map
 	initialized bool
+
+	mu sync.Mutex // Serializes the writes, so the version is incremented atomically
 }
 
-func New(logger *slog.Logger) (*Client, error) {
-	if !client.initialized {
-		client = Client{
-			logger:      logger.With("component", "test-storage"),
-			registry:    sync.Map{},
-			initialized: true,
-		}
-	}
+func New[K cmp.Ordered, T any, PT versioned[T]](logger *slog.Logger) (*Client[K, T, PT], error) {
+	return &Client[K, T, PT]{
+		logger:      logger.With("component", "test-storage"),
+		initialized: true,
+	}, nil
+}
 
-	return &client, nil
+func clone[T any, PT versioned[T]](entry T) T {
+	return PT(&entry).Clone()
 }
@@ -0,0 +1,100 @@
+package map_test_storage
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_history_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// HistoryClient keeps the state transitions of the circuit breakers in memory.
+type HistoryClient struct {
+	logger      *slog.Logger
+	mu          sync.RWMutex
+	records     map[model.Key][]model.Transition
+	initialized bool
+}
+
+func NewHistory(logger *slog.Logger) (*HistoryClient, error) {
+	return &HistoryClient{
+		logger:      logger.With("component", "test-history-storage"),
+		records:     make(map[model.Key][]model.Transition),
+		initialized: true,
+	}, nil
+}
+
+// Shutdown gracefully shuts down the history storage client.
+func (c *HistoryClient) Shutdown(ctx context.Context) error {
+	if !c.initialized {
+		return generic_history_storage.ErrNotInitialized
+	}
+	c.logger.Debug("Shutdown called")
+	c.mu.Lock()
+	c.records = make(map[model.Key][]model.Transition) // Clear all records
+	c.mu.Unlock()
+	return nil
+}
+
+// IsAlive checks if the history storage client is alive.
+func (c *HistoryClient) IsAlive(ctx context.Context) error {
+	if !c.initialized {
+		return generic_history_storage.ErrNotInitialized
+	}
+	c.logger.Debug("IsAlive called")
+	return nil
+}
+
+// AppendRecord stores the transition as the newest record of the key.
+func (c *HistoryClient) AppendRecord(ctx context.Context, primaryKey model.Key, record model.Transition) error {
+	if !c.initialized {
+		return generic_history_storage.ErrNotInitialized
+	}
+	c.logger.Debug("AppendRecord called", "primaryKey", primaryKey, "record", record)
+	c.mu.Lock()
+	c.records[primaryKey] = append(c.records[primaryKey], record)
+	c.mu.Unlock()
+	return nil
+}
+
+// GetRecordsPaginated retrieves up to pageSize records of the key newest first, skipping offset newest records.
+func (c *HistoryClient) GetRecordsPaginated(ctx context.Context, primaryKey model.Key, offset int, pageSize int) ([]model.Transition, error) {
+	if !c.initialized {
+		return nil, generic_history_storage.ErrNotInitialized
+	}
+	c.logger.Debug("GetRecordsPaginated called", "primaryKey", primaryKey, "offset", offset, "pageSize", pageSize)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stored := c.records[primaryKey]
+	offset = max(offset, 0)
+	records := make([]model.Transition, 0, max(min(pageSize, len(stored)-offset), 0))
+	for i := len(stored) - 1 - offset; i >= 0 && len(records) < pageSize; i-- {
+		records = append(records, stored[i])
+	}
+	return records, nil
+}
+
+// CountRecords returns the number of records of the key.
+func (c *HistoryClient) CountRecords(ctx context.Context, primaryKey model.Key) (int, error) {
+	if !c.initialized {
+		return 0, generic_history_storage.ErrNotInitialized
+	}
+	c.logger.Debug("CountRecords called", "primaryKey", primaryKey)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.records[primaryKey]), nil
+}
+
+// RemoveRecords removes all records of the key.
+func (c *HistoryClient) RemoveRecords(ctx context.Context, primaryKey model.Key) error {
+	if !c.initialized {
+		return generic_history_storage.ErrNotInitialized
+	}
+	c.logger.Debug("RemoveRecords called", "primaryKey", primaryKey)
+	c.mu.Lock()
+	delete(c.records, primaryKey)
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,238 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ApplyTo returns a copy of base where the fields set in the config are replaced.
+func (c OptionalBreakerConfig) ApplyTo(base BreakerConfig) BreakerConfig {
+	// ErrorsCntResetTimeoutMs is the legacy window length and WindowLengthMs wins over it,
+	// so the window length of base is dropped if only the legacy field is set
+	if c.ErrorsCntResetTimeoutMs != nil && c.WindowLengthMs == nil {
+		base.WindowLengthMs = 0
+	}
+
+	apply(&base.ErrorsThreshold, c.ErrorsThreshold)
+	apply(&base.ErrorsCntResetTimeoutMs, c.ErrorsCntResetTimeoutMs)
+	apply(&base.ResetTimeoutMs, c.ResetTimeoutMs)
+	apply(&base.WindowType, c.WindowType)
+	apply(&base.WindowLengthMs, c.WindowLengthMs)
+	apply(&base.WindowBucketsCnt, c.WindowBucketsCnt)
+	apply(&base.WindowSize, c.WindowSize)
+	apply(&base.MinimumCalls, c.MinimumCalls)
+	apply(&base.HalfOpenMaxPermits, c.HalfOpenMaxPermits)
+	apply(&base.PermitTimeoutMs, c.PermitTimeoutMs)
+	apply(&base.MaxConcurrentCalls, c.MaxConcurrentCalls)
+	apply(&base.BackoffMultiplier, c.BackoffMultiplier)
+	apply(&base.MaxResetTimeoutMs, c.MaxResetTimeoutMs)
+	apply(&base.SlowCallDurationMs, c.SlowCallDurationMs)
+	apply(&base.SlowCallRateThreshold, c.SlowCallRateThreshold)
+	apply(&base.ThresholdMode, c.ThresholdMode)
+	apply(&base.BaselineAlpha, c.BaselineAlpha)
+	apply(&base.BaselineMultiplier, c.BaselineMultiplier)
+	apply(&base.BaselineDeviations, c.BaselineDeviations)
+	apply(&base.BaselineWarmupCalls, c.BaselineWarmupCalls)
+	apply(&base.BaselineMinThreshold, c.BaselineMinThreshold)
+	apply(&base.RampUpDurationMs, c.RampUpDurationMs)
+	apply(&base.RampUpType, c.RampUpType)
+	apply(&base.RetryBudgetPercentage, c.RetryBudgetPercentage)
+	apply(&base.RetryBudgetMinRetries, c.RetryBudgetMinRetries)
+	if c.FailureClassification != nil {
+		base.FailureClassification = c.FailureClassification
+	}
+	return base
+}
+
+// Validate checks that the values of the set fields are in range.
+func (c *OptionalBreakerConfig) Validate() error {
+	config := c.ApplyTo(BreakerConfig{})
+	return config.Validate()
+}
+
+func apply[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+// Validate checks that the config values are in range, unset (zero) values are allowed.
+func (c *BreakerConfig) Validate() error {
+	if c.ErrorsThreshold < 0 || c.ErrorsThreshold > 100 {
+		return fmt.Errorf("errorsThreshold should be a percentage, got %d", c.ErrorsThreshold)
+	}
+
+	if c.ErrorsCntResetTimeoutMs < 0 {
+		return fmt.Errorf("errorsCntResetTimeoutMs cannot be negative, got %d", c.ErrorsCntResetTimeoutMs)
+	}
+
+	if c.ResetTimeoutMs < 0 {
+		return fmt.Errorf("resetTimeoutMs cannot be negative, got %d", c.ResetTimeoutMs)
+	}
+
+	if c.WindowType != "" && c.WindowType != WindowTypeTime && c.WindowType != WindowTypeCount {
+		return fmt.Errorf("windowType should be '%s' or '%s', got '%s'", WindowTypeTime, WindowTypeCount, c.WindowType)
+	}
+
+	if c.WindowLengthMs < 0 {
+		return fmt.Errorf("windowLengthMs cannot be negative, got %d", c.WindowLengthMs)
+	}
+
+	if c.WindowBucketsCnt < 0 {
+		return fmt.Errorf("windowBucketsCnt cannot be negative, got %d", c.WindowBucketsCnt)
+	}
+
+	if c.WindowSize < 0 {
+		return fmt.Errorf("windowSize cannot be negative, got %d", c.WindowSize)
+	}
+
+	if c.MinimumCalls < 0 {
+		return fmt.Errorf("minimumCalls cannot be negative, got %d", c.MinimumCalls)
+	}
+
+	if c.WindowType == WindowTypeCount && c.WindowSize > 0 && c.MinimumCalls > c.WindowSize {
+		return fmt.Errorf("minimumCalls cannot exceed windowSize, got %d calls for %d window size",
+			c.MinimumCalls, c.WindowSize)
+	}
+
+	if c.HalfOpenMaxPermits < 0 {
+		return fmt.Errorf("halfOpenMaxPermits cannot be negative, got %d", c.HalfOpenMaxPermits)
+	}
+
+	if c.PermitTimeoutMs < 0 {
+		return fmt.Errorf("permitTimeoutMs cannot be negative, got %d", c.PermitTimeoutMs)
+	}
+
+	if c.MaxConcurrentCalls < 0 {
+		return fmt.Errorf("maxConcurrentCalls cannot be negative, got %d", c.MaxConcurrentCalls)
+	}
+
+	if c.BackoffMultiplier != 0 && c.BackoffMultiplier < 1 {
+		return fmt.Errorf("backoffMultiplier cannot be less than 1, got %v", c.BackoffMultiplier)
+	}
+
+	if c.MaxResetTimeoutMs < 0 {
+		return fmt.Errorf("maxResetTimeoutMs cannot be negative, got %d", c.MaxResetTimeoutMs)
+	}
+
+	if c.MaxResetTimeoutMs > 0 && c.MaxResetTimeoutMs < c.ResetTimeoutMs {
+		return fmt.Errorf("maxResetTimeoutMs cannot be less than resetTimeoutMs, got %d and %d",
+			c.MaxResetTimeoutMs, c.ResetTimeoutMs)
+	}
+
+	if c.SlowCallDurationMs < 0 {
+		return fmt.Errorf("slowCallDurationMs cannot be negative, got %d", c.SlowCallDurationMs)
+	}
+
+	if c.SlowCallRateThreshold < 0 || c.SlowCallRateThreshold > 100 {
+		return fmt.Errorf("slowCallRateThreshold should be a percentage, got %d", c.SlowCallRateThreshold)
+	}
+
+	if c.WindowLengthMs > 0 && c.WindowBucketsCnt > c.WindowLengthMs {
+		return fmt.Errorf("windowBucketsCnt cannot exceed windowLengthMs, got %d buckets for %d ms",
+			c.WindowBucketsCnt, c.WindowLengthMs)
+	}
+
+	if c.ThresholdMode != "" && c.ThresholdMode != ThresholdModeFixed && c.ThresholdMode != ThresholdModeAdaptive {
+		return fmt.Errorf("thresholdMode should be '%s' or '%s', got '%s'", ThresholdModeFixed, ThresholdModeAdaptive, c.ThresholdMode)
+	}
+
+	if c.BaselineAlpha < 0 || c.BaselineAlpha > 1 {
+		return fmt.Errorf("baselineAlpha should be between 0 and 1, got %v", c.BaselineAlpha)
+	}
+
+	if c.BaselineMultiplier != 0 && c.BaselineMultiplier < 1 {
+		return fmt.Errorf("baselineMultiplier cannot be less than 1, got %v", c.BaselineMultiplier)
+	}
+
+	if c.BaselineDeviations < 0 {
+		return fmt.Errorf("baselineDeviations cannot be negative, got %v", c.BaselineDeviations)
+	}
+
+	if c.BaselineWarmupCalls < 0 {
+		return fmt.Errorf("baselineWarmupCalls cannot be negative, got %d", c.BaselineWarmupCalls)
+	}
+
+	if c.BaselineMinThreshold < 0 || c.BaselineMinThreshold > 100 {
+		return fmt.Errorf("baselineMinThreshold should be a percentage, got %v", c.BaselineMinThreshold)
+	}
+
+	if c.RampUpDurationMs < 0 {
+		return fmt.Errorf("rampUpDurationMs cannot be negative, got %d", c.RampUpDurationMs)
+	}
+
+	if c.RampUpType != "" && c.RampUpType != RampUpTypeLinear && c.RampUpType != RampUpTypeExponential {
+		return fmt.Errorf("rampUpType should be '%s' or '%s', got '%s'", RampUpTypeLinear, RampUpTypeExponential, c.RampUpType)
+	}
+
+	if c.RetryBudgetPercentage < 0 {
+		return fmt.Errorf("retryBudgetPercentage cannot be negative, got %d", c.RetryBudgetPercentage)
+	}
+
+	if c.RetryBudgetMinRetries < 0 {
+		return fmt.Errorf("retryBudgetMinRetries cannot be negative, got %d", c.RetryBudgetMinRetries)
+	}
+
+	if c.FailureClassification != nil {
+		if err := c.FailureClassification.Validate(); err != nil {
+			return fmt.Errorf("invalid failureClassification: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the categories and that the rules refer to the existing ones.
+func (c *FailureClassification) Validate() error {
+	names := make([]string, 0, len(c.Categories))
+	for _, category := range c.Categories {
+		if category.Name == "" {
+			return fmt.Errorf("category name cannot be empty")
+		}
+
+		if slices.Contains(names, category.Name) {
+			return fmt.Errorf("category '%s' is defined twice", category.Name)
+		}
+		names = append(names, category.Name)
+
+		switch category.Action {
+		case FailureActionIgnore, FailureActionCount:
+		case FailureActionWeight:
+			if category.Weight <= 0 {
+				return fmt.Errorf("weight of category '%s' should be positive, got %v", category.Name, category.Weight)
+			}
+		default:
+			return fmt.Errorf("action of category '%s' should be '%s', '%s' or '%s', got '%s'",
+				category.Name, FailureActionIgnore, FailureActionCount, FailureActionWeight, category.Action)
+		}
+	}
+
+	for i, rule := range c.Rules {
+		if !slices.Contains(names, rule.Category) {
+			return fmt.Errorf("rule %d refers to unknown category '%s'", i, rule.Category)
+		}
+
+		if rule.ErrorCode == "" && rule.Reason == "" {
+			return fmt.Errorf("rule %d should match errorCode or reason", i)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the override mode and expiry.
+func (r *OverrideRequest) Validate() error {
+	switch r.Mode {
+	case OverrideForcedOpen, OverrideDisabled, OverrideIsolated:
+	default:
+		return fmt.Errorf("mode should be '%s', '%s' or '%s', got '%s'",
+			OverrideForcedOpen, OverrideDisabled, OverrideIsolated, r.Mode)
+	}
+
+	if !r.ExpiresAt.IsZero() && !r.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expiresAt should be in the future, got %v", r.ExpiresAt)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+func TestApplyToKeepsLegacyWindowLength(t *testing.T) {
+	// Arrange
+	defaults := model.BreakerConfig{ErrorsCntResetTimeoutMs: 10000, WindowLengthMs: 10000}
+	legacyWindow := 60000
+	legacy := model.OptionalBreakerConfig{ErrorsCntResetTimeoutMs: &legacyWindow}
+
+	// Act
+	config := legacy.ApplyTo(defaults)
+	unset := model.OptionalBreakerConfig{}.ApplyTo(defaults)
+
+	// Assert
+	if config.WindowLengthMs != 0 || config.ErrorsCntResetTimeoutMs != 60000 {
+		t.Fatalf("Expected the legacy window length of 60000 ms to be in effect, but got: %d, %d",
+			config.WindowLengthMs, config.ErrorsCntResetTimeoutMs)
+	}
+	if unset.WindowLengthMs != 10000 {
+		t.Fatalf("Expected the default window length, but got: %d", unset.WindowLengthMs)
+	}
+}
+
+func TestApplyToKeepsExplicitZero(t *testing.T) {
+	// Arrange
+	defaults := model.BreakerConfig{RampUpDurationMs: 30000, SlowCallRateThreshold: 50, ErrorsThreshold: 40}
+	disabled := 0
+	overrides := model.OptionalBreakerConfig{RampUpDurationMs: &disabled, SlowCallRateThreshold: &disabled}
+
+	// Act
+	config := overrides.ApplyTo(defaults)
+
+	// Assert
+	if config.RampUpDurationMs != 0 || config.SlowCallRateThreshold != 0 {
+		t.Fatalf("Expected the ramp-up and the slow call threshold to be disabled, but got: %d, %d",
+			config.RampUpDurationMs, config.SlowCallRateThreshold)
+	}
+	if config.ErrorsThreshold != 40 {
+		t.Fatalf("Expected the unset errorsThreshold to be taken from the defaults, but got: %d", config.ErrorsThreshold)
+	}
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"maps"
+	"slices"
+)
+
+// Clone returns a deep copy of the entry, so the copy can be changed without affecting the original.
+func (e CircuitBreakerEntry) Clone() CircuitBreakerEntry {
+	e.Window = slices.Clone(e.Window)
+	e.Permits = slices.Clone(e.Permits)
+	e.FailureCategoriesCnt = maps.Clone(e.FailureCategoriesCnt)
+	e.Members = slices.Clone(e.Members)
+	e.FailureClassification = e.FailureClassification.Clone()
+	e.ConfigOverrides = e.ConfigOverrides.Clone()
+	if e.ParentID != nil {
+		parentID := *e.ParentID
+		e.ParentID = &parentID
+	}
+	if e.Override != nil {
+		override := *e.Override
+		e.Override = &override
+	}
+	return e
+}
+
+// Clone returns a deep copy of the profile, so the copy can be changed without affecting the original.
+func (p Profile) Clone() Profile {
+	p.OptionalBreakerConfig = p.OptionalBreakerConfig.Clone()
+	return p
+}
+
+// Clone returns a deep copy of the config, the set fields of the copy point to their own values.
+func (c OptionalBreakerConfig) Clone() OptionalBreakerConfig {
+	c.ErrorsThreshold = clonePointer(c.ErrorsThreshold)
+	c.ErrorsCntResetTimeoutMs = clonePointer(c.ErrorsCntResetTimeoutMs)
+	c.ResetTimeoutMs = clonePointer(c.ResetTimeoutMs)
+	c.WindowType = clonePointer(c.WindowType)
+	c.WindowLengthMs = clonePointer(c.WindowLengthMs)
+	c.WindowBucketsCnt = clonePointer(c.WindowBucketsCnt)
+	c.WindowSize = clonePointer(c.WindowSize)
+	c.MinimumCalls = clonePointer(c.MinimumCalls)
+	c.HalfOpenMaxPermits = clonePointer(c.HalfOpenMaxPermits)
+	c.PermitTimeoutMs = clonePointer(c.PermitTimeoutMs)
+	c.MaxConcurrentCalls = clonePointer(c.MaxConcurrentCalls)
+	c.BackoffMultiplier = clonePointer(c.BackoffMultiplier)
+	c.MaxResetTimeoutMs = clonePointer(c.MaxResetTimeoutMs)
+	c.SlowCallDurationMs = clonePointer(c.SlowCallDurationMs)
+	c.SlowCallRateThreshold = clonePointer(c.SlowCallRateThreshold)
+	c.ThresholdMode = clonePointer(c.ThresholdMode)
+	c.BaselineAlpha = clonePointer(c.BaselineAlpha)
+	c.BaselineMultiplier = clonePointer(c.BaselineMultiplier)
+	c.BaselineDeviations = clonePointer(c.BaselineDeviations)
+	c.BaselineWarmupCalls = clonePointer(c.BaselineWarmupCalls)
+	c.BaselineMinThreshold = clonePointer(c.BaselineMinThreshold)
+	c.RampUpDurationMs = clonePointer(c.RampUpDurationMs)
+	c.RampUpType = clonePointer(c.RampUpType)
+	c.RetryBudgetPercentage = clonePointer(c.RetryBudgetPercentage)
+	c.RetryBudgetMinRetries = clonePointer(c.RetryBudgetMinRetries)
+	c.FailureClassification = c.FailureClassification.Clone()
+	return c
+}
+
+// Clone returns a deep copy of the failure classification, nil if it is not set.
+func (c *FailureClassification) Clone() *FailureClassification {
+	if c == nil {
+		return nil
+	}
+
+	return &FailureClassification{
+		Categories: slices.Clone(c.Categories),
+		Rules:      slices.Clone(c.Rules),
+	}
+}
+
+func clonePointer[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	clone := *value
+	return &clone
+}
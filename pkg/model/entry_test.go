@@ -0,0 +1,63 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+func classification() *model.FailureClassification {
+	return &model.FailureClassification{
+		Categories: []model.FailureCategory{{Name: "timeout", Action: model.FailureActionWeight, Weight: 2}},
+		Rules:      []model.FailureRule{{Category: "timeout", Reason: "timeout"}},
+	}
+}
+
+func TestEntryCloneCopiesConfig(t *testing.T) {
+	// Arrange
+	threshold := 30
+	entry := model.CircuitBreakerEntry{
+		BreakerConfig: model.BreakerConfig{FailureClassification: classification()},
+		ConfigOverrides: model.OptionalBreakerConfig{
+			ErrorsThreshold:       &threshold,
+			FailureClassification: classification(),
+		},
+	}
+
+	// Act
+	clone := entry.Clone()
+	clone.FailureClassification.Categories[0].Weight = 5
+	clone.FailureClassification.Rules[0].Reason = "refused"
+	clone.ConfigOverrides.FailureClassification.Categories[0].Weight = 5
+	*clone.ConfigOverrides.ErrorsThreshold = 60
+
+	// Assert
+	if entry.FailureClassification.Categories[0].Weight != 2 || entry.FailureClassification.Rules[0].Reason != "timeout" {
+		t.Fatalf("Expected the original classification to stay unchanged, but got: %+v", entry.FailureClassification)
+	}
+	if entry.ConfigOverrides.FailureClassification.Categories[0].Weight != 2 || *entry.ConfigOverrides.ErrorsThreshold != 30 {
+		t.Fatalf("Expected the original overrides to stay unchanged, but got: %+v", entry.ConfigOverrides)
+	}
+}
+
+func TestProfileCloneCopiesConfig(t *testing.T) {
+	// Arrange
+	threshold := 30
+	profile := model.Profile{
+		Name: "sensor",
+		OptionalBreakerConfig: model.OptionalBreakerConfig{
+			ErrorsThreshold:       &threshold,
+			FailureClassification: classification(),
+		},
+	}
+
+	// Act
+	clone := profile.Clone()
+	clone.FailureClassification.Categories[0].Weight = 5
+	*clone.ErrorsThreshold = 60
+
+	// Assert
+	if profile.FailureClassification.Categories[0].Weight != 2 || *profile.ErrorsThreshold != 30 {
+		t.Fatalf("Expected the original profile to stay unchanged, but got: %+v", profile)
+	}
+}
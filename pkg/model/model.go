@@ -17,27 +17,292 @@ const (
 // NOTE: should match the primary key type in selected database package
 type Key uint
 
+// BreakerConfig represents the settings of a circuit breaker.
+type BreakerConfig struct {
+	ErrorsThreshold         int     `json:"errorsThreshold"`         // Percentage of errors to trip the breaker
+	ErrorsCntResetTimeoutMs int     `json:"errorsCntResetTimeoutMs"` // Time in milliseconds to reset the errors count, used as window length if WindowLengthMs is not set
+	ResetTimeoutMs          int     `json:"resetTimeoutMs"`          // Time in milliseconds to reset the breaker
+	WindowType              string  `json:"windowType"`              // Type of the rolling window, see WindowTypeTime and WindowTypeCount
+	WindowLengthMs          int     `json:"windowLengthMs"`          // Length in milliseconds of the time window the errors are counted in
+	WindowBucketsCnt        int     `json:"windowBucketsCnt"`        // Number of buckets the time window is split into
+	WindowSize              int     `json:"windowSize"`              // Number of the last calls the count window consists of
+	MinimumCalls            int     `json:"minimumCalls"`            // Number of calls in the window required before the breaker may trip
+	HalfOpenMaxPermits      int     `json:"halfOpenMaxPermits"`      // Number of trial calls allowed in the half-open state
+	PermitTimeoutMs         int     `json:"permitTimeoutMs"`         // Time in milliseconds after which a permit without reported outcome is released
+	MaxConcurrentCalls      int     `json:"maxConcurrentCalls"`      // Number of calls allowed to be in flight at once, unlimited if zero
+	BackoffMultiplier       float64 `json:"backoffMultiplier"`       // Multiplier applied to the reset timeout on each consecutive trip
+	MaxResetTimeoutMs       int     `json:"maxResetTimeoutMs"`       // Upper limit in milliseconds of the reset timeout grown by the backoff
+	SlowCallDurationMs      int     `json:"slowCallDurationMs"`      // Duration in milliseconds starting from which a call is considered slow
+	SlowCallRateThreshold   int     `json:"slowCallRateThreshold"`   // Percentage of slow calls to trip the breaker
+	ThresholdMode           string  `json:"thresholdMode"`           // How the error rate threshold is chosen, see ThresholdModeFixed and ThresholdModeAdaptive
+	BaselineAlpha           float64 `json:"baselineAlpha"`           // Weight of the newest call in the EWMA baseline of the error rate
+	BaselineMultiplier      float64 `json:"baselineMultiplier"`      // The adaptive mode trips at this multiple of the baseline error rate
+	BaselineDeviations      float64 `json:"baselineDeviations"`      // The adaptive mode trips at this number of standard deviations above the baseline error rate
+	BaselineWarmupCalls     int     `json:"baselineWarmupCalls"`     // Calls collected into the baseline before the adaptive mode is in effect, ErrorsThreshold is used until then
+	BaselineMinThreshold    float64 `json:"baselineMinThreshold"`    // Lowest error rate percentage the adaptive mode trips at
+	RampUpDurationMs        int     `json:"rampUpDurationMs"`        // Time in milliseconds the admitted traffic grows to 100% for after the breaker recovered
+	RampUpType              string  `json:"rampUpType"`              // How the admitted traffic grows, see RampUpTypeLinear and RampUpTypeExponential
+	RetryBudgetPercentage   int     `json:"retryBudgetPercentage"`   // Percentage of the first attempts in the rolling window the retries may not exceed, no budget if zero
+	RetryBudgetMinRetries   int     `json:"retryBudgetMinRetries"`   // Retries allowed in the rolling window regardless of the number of first attempts
+
+	FailureClassification *FailureClassification `json:"failureClassification,omitempty"` // Rules mapping the reported failures to categories
+}
+
+// OptionalBreakerConfig represents the settings of a circuit breaker set explicitly, see BreakerConfig for their meaning.
+// The unset (nil) fields are taken from the profile or the service defaults, while the zero ones are
+// kept, so a feature enabled by the defaults can be disabled for a device by setting its field to zero.
+type OptionalBreakerConfig struct {
+	ErrorsThreshold         *int     `json:"errorsThreshold,omitempty"`
+	ErrorsCntResetTimeoutMs *int     `json:"errorsCntResetTimeoutMs,omitempty"`
+	ResetTimeoutMs          *int     `json:"resetTimeoutMs,omitempty"`
+	WindowType              *string  `json:"windowType,omitempty"`
+	WindowLengthMs          *int     `json:"windowLengthMs,omitempty"`
+	WindowBucketsCnt        *int     `json:"windowBucketsCnt,omitempty"`
+	WindowSize              *int     `json:"windowSize,omitempty"`
+	MinimumCalls            *int     `json:"minimumCalls,omitempty"`
+	HalfOpenMaxPermits      *int     `json:"halfOpenMaxPermits,omitempty"`
+	PermitTimeoutMs         *int     `json:"permitTimeoutMs,omitempty"`
+	MaxConcurrentCalls      *int     `json:"maxConcurrentCalls,omitempty"`
+	BackoffMultiplier       *float64 `json:"backoffMultiplier,omitempty"`
+	MaxResetTimeoutMs       *int     `json:"maxResetTimeoutMs,omitempty"`
+	SlowCallDurationMs      *int     `json:"slowCallDurationMs,omitempty"`
+	SlowCallRateThreshold   *int     `json:"slowCallRateThreshold,omitempty"`
+	ThresholdMode           *string  `json:"thresholdMode,omitempty"`
+	BaselineAlpha           *float64 `json:"baselineAlpha,omitempty"`
+	BaselineMultiplier      *float64 `json:"baselineMultiplier,omitempty"`
+	BaselineDeviations      *float64 `json:"baselineDeviations,omitempty"`
+	BaselineWarmupCalls     *int     `json:"baselineWarmupCalls,omitempty"`
+	BaselineMinThreshold    *float64 `json:"baselineMinThreshold,omitempty"`
+	RampUpDurationMs        *int     `json:"rampUpDurationMs,omitempty"`
+	RampUpType              *string  `json:"rampUpType,omitempty"`
+	RetryBudgetPercentage   *int     `json:"retryBudgetPercentage,omitempty"`
+	RetryBudgetMinRetries   *int     `json:"retryBudgetMinRetries,omitempty"`
+
+	FailureClassification *FailureClassification `json:"failureClassification,omitempty"`
+}
+
+const (
+	// WindowTypeTime means the errors are counted over the calls reported within the last WindowLengthMs.
+	WindowTypeTime = "time"
+	// WindowTypeCount means the errors are counted over the last WindowSize reported calls.
+	WindowTypeCount = "count"
+)
+
+const (
+	// FailureActionIgnore means the failures of the category are neither counted as failures nor as calls.
+	FailureActionIgnore = "ignore"
+	// FailureActionCount means the failures of the category are counted as usual.
+	FailureActionCount = "count"
+	// FailureActionWeight means each failure of the category is counted Weight times.
+	FailureActionWeight = "weight"
+)
+
+// FailureCategory represents how the failures of one category affect the error rate.
+type FailureCategory struct {
+	Name   string  `json:"name" yaml:"name"`
+	Action string  `json:"action" yaml:"action"` // See FailureActionIgnore, FailureActionCount and FailureActionWeight
+	Weight float64 `json:"weight" yaml:"weight"` // Used with FailureActionWeight only
+}
+
+// FailureRule assigns the category to the failures matching the error code and the reason.
+type FailureRule struct {
+	Category  string `json:"category" yaml:"category"`
+	ErrorCode string `json:"errorCode" yaml:"error_code"` // Error code to match exactly, any if not set
+	Reason    string `json:"reason" yaml:"reason"`        // Text the failure reason should contain, case-insensitive, any if not set
+}
+
+// FailureClassification represents the categories of the failures and the rules assigning them,
+// the first matching rule wins.
+type FailureClassification struct {
+	Categories []FailureCategory `json:"categories" yaml:"categories"`
+	Rules      []FailureRule     `json:"rules" yaml:"rules"`
+}
+
+const (
+	// ThresholdModeFixed means the breaker trips at ErrorsThreshold.
+	ThresholdModeFixed = "fixed"
+	// ThresholdModeAdaptive means the breaker trips when the error rate goes far enough above the baseline of the device.
+	ThresholdModeAdaptive = "adaptive"
+)
+
+const (
+	// RampUpTypeLinear means the admitted percentage of calls grows evenly over the ramp-up.
+	RampUpTypeLinear = "linear"
+	// RampUpTypeExponential means the admitted percentage of calls starts at 1% and doubles in equal steps over the ramp-up.
+	RampUpTypeExponential = "exponential"
+)
+
+// Baseline represents the exponentially weighted moving average of the device error rate, sampled on each call.
+type Baseline struct {
+	ErrorRate float64 `json:"errorRate"` // Percentage of failed calls
+	Variance  float64 `json:"variance"`  // Variance of a single call outcome, in squared percents
+	CallsCnt  int     `json:"callsCnt"`  // Calls collected into the baseline
+}
+
+// Bucket represents the calls reported within one time slot of the time window,
+// or a single call of the count window.
+type Bucket struct {
+	StartedAt    time.Time `json:"startedAt"`
+	CallsCnt     int       `json:"callsCnt"`
+	FailuresCnt  int       `json:"failuresCnt"`
+	SlowCallsCnt int       `json:"slowCallsCnt"`
+	RetriesCnt   int       `json:"retriesCnt"` // Calls reported as retries
+
+	CallsWeight    float64 `json:"callsWeight"`    // Calls with the failure weights applied
+	FailuresWeight float64 `json:"failuresWeight"` // Failed calls with the failure weights applied
+}
+
+const (
+	// OverrideForcedOpen denies all calls, the outcomes are still collected in the rolling window.
+	OverrideForcedOpen = "forced-open"
+	// OverrideDisabled allows all calls, the outcomes are collected in the rolling window but never trip the breaker.
+	OverrideDisabled = "disabled"
+	// OverrideIsolated denies all calls and ignores the reported outcomes.
+	OverrideIsolated = "isolated"
+)
+
+// Override represents an administrative override which wins over the automatic state transitions.
+type Override struct {
+	Mode      string    `json:"mode"`      // See OverrideForcedOpen, OverrideDisabled and OverrideIsolated
+	Reason    string    `json:"reason"`    // Why the override was set
+	SetAt     time.Time `json:"setAt"`     // Timestamp when the override was set
+	ExpiresAt time.Time `json:"expiresAt"` // The override is cleared at this moment, never if not set
+}
+
+const (
+	// TriggerAutomatic means the state was changed by the circuit breaker itself.
+	TriggerAutomatic = "automatic"
+	// TriggerReset means the state was changed by the reset request.
+	TriggerReset = "reset"
+	// TriggerOverride means the effective state was changed by setting, clearing or expiry of the override.
+	TriggerOverride = "override"
+)
+
+// Transition represents a single change of the circuit breaker state.
+type Transition struct {
+	DeviceID     Key       `json:"deviceID"`
+	From         State     `json:"from"`
+	To           State     `json:"to"`
+	Timestamp    time.Time `json:"timestamp"`
+	Trigger      string    `json:"trigger"`      // See TriggerAutomatic, TriggerReset and TriggerOverride
+	ErrorRate    float64   `json:"errorRate"`    // Percentage of failed calls in the rolling window right before the change
+	SlowCallRate float64   `json:"slowCallRate"` // Percentage of slow calls in the rolling window right before the change
+}
+
+// Permit represents a permission to call the device handed out by the circuit breaker.
+type Permit struct {
+	ID       string    `json:"id"`
+	IssuedAt time.Time `json:"issuedAt"`
+}
+
 // CircuitBreakerEntry represents a circuit breaker for a specific device.
 type CircuitBreakerEntry struct {
-	DeviceID                Key       `json:"deviceID"`
-	State                   State     `json:"state"`                   // State of the circuit breaker
-	LastChanged             time.Time `json:"lastChanged"`             // Timestamp of the last state change
-	ErrorsThreshold         int       `json:"errorsThreshold"`         // Percentage of errors to trip the breaker
-	ErrorsCntResetTimeoutMs int       `json:"errorsCntResetTimeoutMs"` // Time in milliseconds to reset the errors count
-	ResetTimeoutMs          int       `json:"resetTimeoutMs"`          // Time in milliseconds to reset the breaker
+	DeviceID    Key       `json:"deviceID"`
+	Version     uint64    `json:"version"`     // Incremented by the storage on each write, see generic_storage.StorageClient.CompareAndSwap
+	State       State     `json:"state"`       // State of the circuit breaker
+	LastChanged time.Time `json:"lastChanged"` // Timestamp of the last state change
+
+	BreakerConfig // Effective config, the device overrides applied on top of the profile and the service defaults
+
+	Profile         string                `json:"profile,omitempty"` // Profile the device config is based on, if any
+	ConfigOverrides OptionalBreakerConfig `json:"configOverrides"`   // Config fields set for the device explicitly
+
+	Window            []Bucket `json:"window"`            // Buckets of the rolling window, oldest first
+	LastFailureReason string   `json:"lastFailureReason"` // Reason of the last reported failure
+
+	FailureCategoriesCnt map[string]int `json:"failureCategoriesCnt,omitempty"` // Failures per category since the breaker last closed, the ignored ones included
+	Baseline             Baseline       `json:"baseline"`                       // Error rate baseline of the device, collected in the adaptive mode only
+
+	Permits              []Permit  `json:"permits"`              // Permits which outcome is not reported yet
+	RejectedCallsCnt     int       `json:"rejectedCallsCnt"`     // Calls rejected because MaxConcurrentCalls calls were in flight
+	HalfOpenSuccessesCnt int       `json:"halfOpenSuccessesCnt"` // Trial calls succeeded in the half-open state
+	RampUpStartedAt      time.Time `json:"rampUpStartedAt"`      // Timestamp when the breaker recovered from the half-open state, zero if there is no ramp-up
+	ConsecutiveTripsCnt  int       `json:"consecutiveTripsCnt"`  // Trips since the breaker was closed last time, drives the reset timeout backoff
+
+	Override *Override `json:"override,omitempty"` // Administrative override of the state, if any
+
+	ParentID *Key  `json:"parentID,omitempty"` // Parent breaker of the group the device belongs to, if any
+	Members  []Key `json:"members,omitempty"`  // Devices of the group this breaker is the parent of
 }
 
 // ConfigUpdateRequest represents the payload for updating circuit breaker configuration.
 type ConfigUpdateRequest struct {
-	ErrorsThreshold         int `json:"errorsThreshold"`
-	ErrorsCntResetTimeoutMs int `json:"errorsCntResetTimeoutMs"`
-	ResetTimeoutMs          int `json:"resetTimeoutMs"`
+	Profile string `json:"profile,omitempty"` // Profile the config is based on, the fields set explicitly override it
+
+	OptionalBreakerConfig
 }
 
 // ConfigUpdateResponse represents the response for updating circuit breaker configuration.
 type ConfigUpdateResponse struct {
-	DeviceID string              `json:"deviceID"`
-	Config   ConfigUpdateRequest `json:"config"`
+	DeviceID string          `json:"deviceID"`
+	Config   EffectiveConfig `json:"config"`
+}
+
+// EffectiveConfig represents the config in effect for a device, the overrides applied on top of the profile and the defaults.
+type EffectiveConfig struct {
+	Profile string `json:"profile,omitempty"` // Profile the config is based on, if any
+
+	BreakerConfig
+}
+
+// ImportRequest represents the payload for creating the circuit breakers of many devices at once.
+type ImportRequest struct {
+	Devices []DeviceConfig `json:"devices"`
+}
+
+// DeviceConfig represents the config of a single device in the import request.
+type DeviceConfig struct {
+	DeviceID Key `json:"deviceID"`
+	ConfigUpdateRequest
+}
+
+// ImportResponse represents the response for importing the circuit breakers.
+type ImportResponse struct {
+	ImportedCnt int            `json:"importedCnt"`
+	Results     []ImportResult `json:"results"` // Result of each device in the order of the request
+}
+
+// ImportResult represents the outcome of importing the circuit breaker of a single device.
+type ImportResult struct {
+	DeviceID Key    `json:"deviceID"`
+	Error    string `json:"error,omitempty"` // Why the device was not imported, empty on success
+}
+
+// ReportFailureRequest represents the payload for reporting a failed call.
+type ReportFailureRequest struct {
+	FailureReason string `json:"failureReason"`
+	ErrorCode     string `json:"errorCode"`  // Error code of the failure, if any, used by the failure classification
+	PermitID      string `json:"permitID"`   // Permit the call was made with, if any
+	DurationMs    int    `json:"durationMs"` // Duration of the call in milliseconds, if measured
+	Retry         bool   `json:"retry"`      // Whether the call was a retry, counted against the retry budget
+}
+
+// ReportFailureResponse represents the response for reporting a failed call.
+type ReportFailureResponse struct {
+	DeviceID Key   `json:"deviceID"`
+	State    State `json:"state"` // State of the circuit breaker after the failure was registered
+}
+
+// AcquireResponse represents the response for asking a permission to call the device.
+type AcquireResponse struct {
+	DeviceID Key    `json:"deviceID"`
+	Allowed  bool   `json:"allowed"`
+	PermitID string `json:"permitID,omitempty"` // Should be passed when reporting the outcome of the call
+	State    State  `json:"state"`              // State of the circuit breaker
+}
+
+// OverrideRequest represents the payload for setting an administrative override.
+type OverrideRequest struct {
+	Mode      string    `json:"mode"`
+	Reason    string    `json:"reason"`
+	ExpiresAt time.Time `json:"expiresAt"` // Optional, the override never expires if not set
+}
+
+// OverrideResponse represents the response for setting or clearing an administrative override.
+type OverrideResponse struct {
+	DeviceID Key       `json:"deviceID"`
+	State    State     `json:"state"`              // Effective state of the circuit breaker
+	Override *Override `json:"override,omitempty"` // Override in effect, if any
 }
 
 // ResetResponse represents the response for resetting a circuit breaker.
@@ -46,11 +311,108 @@ type ResetResponse struct {
 	NewState State  `json:"newState"` // StateClosed
 }
 
+// RetryBudgetResponse represents the response for checking the retry budget of a device.
+type RetryBudgetResponse struct {
+	DeviceID         Key  `json:"deviceID"`
+	Allowed          bool `json:"allowed"`          // Whether one more retry fits into the budget
+	FirstAttemptsCnt int  `json:"firstAttemptsCnt"` // Calls reported as first attempts in the rolling window
+	RetriesCnt       int  `json:"retriesCnt"`       // Calls reported as retries in the rolling window
+	RetriesLimit     *int `json:"retriesLimit"`     // Retries allowed in the rolling window, null if there is no budget
+}
+
+// ReleaseRequest represents the payload for releasing a permit without reporting the outcome of the call.
+type ReleaseRequest struct {
+	PermitID string `json:"permitID"` // Permit the call was made with
+}
+
+// ReleaseResponse represents the response for releasing a permit.
+type ReleaseResponse struct {
+	DeviceID Key  `json:"deviceID"`
+	Released bool `json:"released"` // False if the permit is unknown, e.g. already expired
+}
+
+// ReportSuccessRequest represents the optional payload for reporting a successful call.
+type ReportSuccessRequest struct {
+	PermitID   string `json:"permitID"`   // Permit the call was made with, if any
+	DurationMs int    `json:"durationMs"` // Duration of the call in milliseconds, if measured
+	Retry      bool   `json:"retry"`      // Whether the call was a retry, counted against the retry budget
+}
+
+// ReportSuccessResponse represents the response for reporting a successful call.
+type ReportSuccessResponse struct {
+	DeviceID Key   `json:"deviceID"`
+	State    State `json:"state"` // State of the circuit breaker after the success was registered
+}
+
 // StatusResponse represents the response for retrieving the status of a circuit breaker.
 type StatusResponse struct {
-	DeviceID    string    `json:"deviceID"`
-	State       State     `json:"state"`       // State of the circuit breaker
-	LastChanged time.Time `json:"lastChanged"` // Timestamp of the last state change
+	DeviceID     string    `json:"deviceID"`
+	State        State     `json:"state"`        // Effective state of the circuit breaker, the override and the parent are taken into account
+	LastChanged  time.Time `json:"lastChanged"`  // Timestamp of the last state change
+	CallsCnt     int       `json:"callsCnt"`     // Calls reported in the rolling window
+	FailuresCnt  int       `json:"failuresCnt"`  // Failed calls reported in the rolling window
+	ErrorRate    float64   `json:"errorRate"`    // Percentage of failed calls in the rolling window
+	SlowCallsCnt int       `json:"slowCallsCnt"` // Slow calls reported in the rolling window
+	SlowCallRate float64   `json:"slowCallRate"` // Percentage of slow calls in the rolling window
+	RetriesCnt   int       `json:"retriesCnt"`   // Calls reported as retries in the rolling window
+	MinimumCalls int       `json:"minimumCalls"` // Number of calls in the window required before the breaker may trip
+
+	ErrorRateThreshold float64   `json:"errorRateThreshold"` // Error rate percentage the breaker trips at, fixed or adaptive, zero if it never trips on errors
+	RampUpPercentage   float64   `json:"rampUpPercentage"`   // Percentage of calls admitted while the breaker ramps up after recovery, 100 otherwise
+	Baseline           *Baseline `json:"baseline,omitempty"` // Error rate baseline of the device, in the adaptive mode only
+
+	FailureCategoriesCnt map[string]int `json:"failureCategoriesCnt"` // Failures per category since the breaker last closed
+
+	ConsecutiveTripsCnt   int `json:"consecutiveTripsCnt"`   // Trips since the breaker was closed last time
+	CurrentResetTimeoutMs int `json:"currentResetTimeoutMs"` // Reset timeout with the backoff applied
+
+	InFlightCallsCnt   int `json:"inFlightCallsCnt"`   // Calls acquired and not reported or released yet
+	MaxConcurrentCalls int `json:"maxConcurrentCalls"` // Number of calls allowed to be in flight at once, unlimited if zero
+	RejectedCallsCnt   int `json:"rejectedCallsCnt"`   // Calls rejected because MaxConcurrentCalls calls were in flight
+
+	Override *Override `json:"override,omitempty"` // Administrative override in effect, if any
+	ParentID *Key      `json:"parentID,omitempty"` // Parent breaker of the group the device belongs to, if any
+	Profile  string    `json:"profile,omitempty"`  // Profile the device config is based on, if any
+}
+
+// GroupStatusResponse represents the response for retrieving the status of a group of circuit breakers.
+type GroupStatusResponse struct {
+	Parent  StatusResponse   `json:"parent"`
+	Members []StatusResponse `json:"members"`
+}
+
+// GroupMembersResponse represents the response for changing the members of a group.
+type GroupMembersResponse struct {
+	DeviceID Key   `json:"deviceID"` // Parent breaker of the group
+	Members  []Key `json:"members"`
+}
+
+// Profile represents a named circuit breaker config shared by many devices.
+type Profile struct {
+	Name    string `json:"name"`
+	Version uint64 `json:"version"` // Incremented by the storage on each write
+	OptionalBreakerConfig
+}
+
+// ProfileRequest represents the payload for creating or updating a profile.
+type ProfileRequest struct {
+	OptionalBreakerConfig
+}
+
+// ProfileUpdateResponse represents the response for creating or updating a profile.
+type ProfileUpdateResponse struct {
+	Profile           Profile `json:"profile"`
+	UpdatedDevicesCnt int     `json:"updatedDevicesCnt"` // Devices the new profile config was applied to
+}
+
+// HistoryResponse represents the response for retrieving the state transition history of a circuit breaker.
+type HistoryResponse struct {
+	DeviceID    Key          `json:"deviceID"`
+	Page        int          `json:"page"`
+	PageSize    int          `json:"pageSize"`
+	TotalItems  int          `json:"totalItems"`
+	TotalPages  int          `json:"totalPages"`
+	Transitions []Transition `json:"transitions"` // Newest first
 }
 
 // PaginatedResponse represents the paginated response for retrieving multiple circuit breakers.
@@ -59,5 +421,13 @@ type PaginatedResponse struct {
 	PageSize        int                   `json:"pageSize"`
 	TotalItems      int                   `json:"totalItems"`
 	TotalPages      int                   `json:"totalPages"`
+	NextCursor      string                `json:"nextCursor,omitempty"` // Cursor of the next page, omitted on the last page
+	CircuitBreakers []CircuitBreakerEntry `json:"circuitBreakers"`
+}
+
+// CursorPaginatedResponse represents the page of circuit breakers requested with a cursor.
+type CursorPaginatedResponse struct {
+	PageSize        int                   `json:"pageSize"`
+	NextCursor      string                `json:"nextCursor,omitempty"` // Cursor of the next page, omitted on the last page
 	CircuitBreakers []CircuitBreakerEntry `json:"circuitBreakers"`
 }
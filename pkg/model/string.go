@@ -15,3 +15,39 @@ func (s State) String() string {
 		return fmt.Sprintf("UnknownState(%d)", s)
 	}
 }
+
+// API representation of the State, see docs/api.yaml.
+const (
+	apiStateClosed   = "CLOSED"
+	apiStateOpen     = "OPEN"
+	apiStateHalfOpen = "HALF-OPEN"
+)
+
+// MarshalText encodes the State as documented in the API.
+func (s State) MarshalText() ([]byte, error) {
+	switch s {
+	case StateClosed:
+		return []byte(apiStateClosed), nil
+	case StateOpen:
+		return []byte(apiStateOpen), nil
+	case StateHalfOpen:
+		return []byte(apiStateHalfOpen), nil
+	default:
+		return nil, fmt.Errorf("unknown state: %d", s)
+	}
+}
+
+// UnmarshalText decodes the State from its API representation.
+func (s *State) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case apiStateClosed:
+		*s = StateClosed
+	case apiStateOpen:
+		*s = StateOpen
+	case apiStateHalfOpen:
+		*s = StateHalfOpen
+	default:
+		return fmt.Errorf("unknown state: '%s'", text)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package model
+
+// The accessors let the generic storage backends increment the version on each write.
+
+func (e *CircuitBreakerEntry) GetVersion() uint64 {
+	return e.Version
+}
+
+func (e *CircuitBreakerEntry) SetVersion(version uint64) {
+	e.Version = version
+}
+
+func (p *Profile) GetVersion() uint64 {
+	return p.Version
+}
+
+func (p *Profile) SetVersion(version uint64) {
+	p.Version = version
+}
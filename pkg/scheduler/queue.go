@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+type deadline struct {
+	deviceID model.Key
+	version  uint64 // Version of the entry the deadline was computed from, 0 if unknown
+	at       time.Time
+	index    int
+}
+
+// deadlineQueue is a min-heap of deadlines ordered by time, see container/heap.
+type deadlineQueue []*deadline
+
+func (q deadlineQueue) Len() int { return len(q) }
+
+func (q deadlineQueue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+
+func (q deadlineQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *deadlineQueue) Push(x any) {
+	item := x.(*deadline)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *deadlineQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*q = old[:n-1]
+	return item
+}
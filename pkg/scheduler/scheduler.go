@@ -0,0 +1,252 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_history_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/state_machine"
+)
+
+// The scheduler keeps deadlines in memory and sleeps until the nearest one,
+// the storage is scanned only once on Load to recover the deadlines after restart.
+
+// Scheduler moves open circuit breakers to StateHalfOpen once their reset timeout has passed
+// and clears the overrides once they have expired.
+type Scheduler struct {
+	storage generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry]
+	history generic_history_storage.HistoryStorageClient[model.Key, model.Transition]
+	logger  *slog.Logger
+
+	mu        sync.Mutex
+	queue     deadlineQueue
+	deadlines map[model.Key]*deadline
+	wakeup    chan struct{}
+}
+
+func New(storage generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry], history generic_history_storage.HistoryStorageClient[model.Key, model.Transition], logger *slog.Logger) (*Scheduler, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage cannot be nil")
+	}
+
+	if history == nil {
+		return nil, fmt.Errorf("history storage cannot be nil")
+	}
+
+	if logger == nil {
+		return nil, fmt.Errorf("logger cannot be nil")
+	}
+
+	return &Scheduler{
+		storage:   storage,
+		history:   history,
+		logger:    logger.With("component", "scheduler"),
+		deadlines: make(map[model.Key]*deadline),
+		wakeup:    make(chan struct{}, 1),
+	}, nil
+}
+
+// Load recomputes the deadlines of all circuit breakers from storage.
+func (s *Scheduler) Load(ctx context.Context) error {
+	entries, err := s.storage.GetAllEntries(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load entries: %w", err)
+	}
+
+	for i := range entries {
+		s.Track(&entries[i])
+	}
+
+	s.logger.Info("Deadlines loaded", "count", s.len())
+	return nil
+}
+
+// Track schedules the next transition of the circuit breaker, or cancels it if there is nothing to schedule.
+// The entry older than the one the current deadline was computed from is ignored, so the requests
+// tracking their results out of order cannot cancel or postpone the deadline of a newer entry.
+// The deadline left by an older entry once the newer one cancelled it is harmless, transit re-reads the entry.
+func (s *Scheduler) Track(entry *model.CircuitBreakerEntry) {
+	at, ok := state_machine.NextTransition(entry, time.Now())
+
+	s.mu.Lock()
+	if item, exists := s.deadlines[entry.DeviceID]; exists && item.version > entry.Version {
+		s.mu.Unlock()
+		return
+	}
+	if !ok {
+		s.cancel(entry.DeviceID)
+		s.mu.Unlock()
+		return
+	}
+	s.schedule(entry.DeviceID, entry.Version, at)
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// Schedule sets the deadline of the circuit breaker, replacing the previous one.
+func (s *Scheduler) Schedule(deviceID model.Key, at time.Time) {
+	s.mu.Lock()
+	version := uint64(0)
+	if item, exists := s.deadlines[deviceID]; exists {
+		version = item.version
+	}
+	s.schedule(deviceID, version, at)
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// Cancel removes the deadline of the circuit breaker, if any.
+func (s *Scheduler) Cancel(deviceID model.Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancel(deviceID)
+}
+
+// schedule sets the deadline computed from the entry version, s.mu must be held.
+func (s *Scheduler) schedule(deviceID model.Key, version uint64, at time.Time) {
+	if item, exists := s.deadlines[deviceID]; exists {
+		item.version = version
+		item.at = at
+		heap.Fix(&s.queue, item.index)
+		return
+	}
+
+	item := &deadline{deviceID: deviceID, version: version, at: at}
+	heap.Push(&s.queue, item)
+	s.deadlines[deviceID] = item
+}
+
+// cancel removes the deadline, s.mu must be held.
+func (s *Scheduler) cancel(deviceID model.Key) {
+	item, exists := s.deadlines[deviceID]
+	if !exists {
+		return
+	}
+	heap.Remove(&s.queue, item.index)
+	delete(s.deadlines, deviceID)
+}
+
+// Run processes the deadlines until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			s.processDue(ctx, time.Now())
+		case <-s.wakeup:
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if next, ok := s.next(); ok {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+func (s *Scheduler) processDue(ctx context.Context, now time.Time) {
+	for _, deviceID := range s.popDue(now) {
+		if err := s.transit(ctx, deviceID, now); err != nil {
+			s.logger.Error("Failed to process deadline", "deviceID", deviceID, "error", err)
+		}
+	}
+}
+
+func (s *Scheduler) transit(ctx context.Context, deviceID model.Key, now time.Time) error {
+	entry, err := s.storage.GetEntry(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to get entry: %w", err)
+	}
+
+	version := entry.Version
+	hadOverride := entry.Override != nil
+	var transitions []model.Transition
+	if transition, changed := state_machine.ExpireOverride(&entry, now); changed {
+		transitions = append(transitions, transition)
+	}
+	if transition, changed := state_machine.HalfOpen(&entry, now); changed {
+		transitions = append(transitions, transition)
+	}
+
+	if len(transitions) == 0 && hadOverride == (entry.Override != nil) {
+		// the entry was changed since the deadline was scheduled
+		s.Track(&entry)
+		return nil
+	}
+
+	err = s.storage.CompareAndSwap(ctx, deviceID, version, entry)
+	if errors.Is(err, generic_storage.ErrConflict) {
+		// the entry was changed concurrently, process it once again
+		s.Schedule(deviceID, now)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store entry: %w", err)
+	}
+	entry.Version = version + 1
+	s.Track(&entry)
+
+	for _, transition := range transitions {
+		s.logger.Info("Circuit breaker state changed",
+			"deviceID", deviceID, "from", transition.From, "to", transition.To, "trigger", transition.Trigger)
+		if err := s.history.AppendRecord(ctx, deviceID, transition); err != nil {
+			s.logger.Error("Failed to record transition", "deviceID", deviceID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) popDue(now time.Time) []model.Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []model.Key
+	for s.queue.Len() > 0 && !s.queue[0].at.After(now) {
+		item := heap.Pop(&s.queue).(*deadline)
+		delete(s.deadlines, item.deviceID)
+		due = append(due, item.deviceID)
+	}
+	return due
+}
+
+func (s *Scheduler) next() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.queue.Len() == 0 {
+		return time.Time{}, false
+	}
+	return s.queue[0].at, true
+}
+
+func (s *Scheduler) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.queue.Len()
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
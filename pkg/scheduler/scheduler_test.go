@@ -0,0 +1,109 @@
+package scheduler_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/map_test_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/scheduler"
+)
+
+func TestSchedulerMovesOpenToHalfOpen(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	storage, _ := map_test_storage.New[model.Key, model.CircuitBreakerEntry](logger)
+	history, _ := map_test_storage.NewHistory(logger)
+	expired := model.CircuitBreakerEntry{
+		DeviceID:      1,
+		State:         model.StateOpen,
+		LastChanged:   time.Now().Add(-time.Minute),
+		BreakerConfig: model.BreakerConfig{ResetTimeoutMs: 1000},
+	}
+	pending := model.CircuitBreakerEntry{
+		DeviceID:      2,
+		State:         model.StateOpen,
+		LastChanged:   time.Now(),
+		BreakerConfig: model.BreakerConfig{ResetTimeoutMs: 100},
+	}
+	_ = storage.UpsertEntry(ctx, expired.DeviceID, expired)
+	_ = storage.UpsertEntry(ctx, pending.DeviceID, pending)
+
+	s, err := scheduler.New(storage, history, logger)
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+
+	// Act
+	if err := s.Load(ctx); err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	go s.Run(ctx)
+
+	// Assert
+	deadline := time.Now().Add(2 * time.Second)
+	for _, deviceID := range []model.Key{expired.DeviceID, pending.DeviceID} {
+		for {
+			entry, _ := storage.GetEntry(ctx, deviceID)
+			if entry.State == model.StateHalfOpen {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected device %d to be moved to %v, but got: %v", deviceID, model.StateHalfOpen, entry.State)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	records, _ := history.GetRecordsPaginated(ctx, expired.DeviceID, 0, 10)
+	if len(records) != 1 || records[0].From != model.StateOpen || records[0].To != model.StateHalfOpen {
+		t.Fatalf("Expected the transition to be recorded, but got: %+v", records)
+	}
+}
+
+func TestSchedulerIgnoresOlderEntry(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	storage, _ := map_test_storage.New[model.Key, model.CircuitBreakerEntry](logger)
+	history, _ := map_test_storage.NewHistory(logger)
+	_ = storage.UpsertEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1, State: model.StateClosed})
+	older, _ := storage.GetEntry(ctx, 1)
+	_ = storage.UpsertEntry(ctx, 1, model.CircuitBreakerEntry{
+		DeviceID:      1,
+		State:         model.StateOpen,
+		LastChanged:   time.Now(),
+		BreakerConfig: model.BreakerConfig{ResetTimeoutMs: 100},
+	})
+	newer, _ := storage.GetEntry(ctx, 1)
+
+	s, err := scheduler.New(storage, history, logger)
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+
+	// Act
+	s.Track(&newer)
+	s.Track(&older)
+	go s.Run(ctx)
+
+	// Assert
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entry, _ := storage.GetEntry(ctx, 1)
+		if entry.State == model.StateHalfOpen {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the device to be moved to %v, but got: %v", model.StateHalfOpen, entry.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -5,13 +5,16 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 
 	"github.com/gin-gonic/gin"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_history_storage"
 	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
 	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/scheduler"
 )
 
-func New(cfg *Config, storage generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry], logger *slog.Logger) (*Service, error) {
+func New(cfg *Config, options Options, storage generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry], history generic_history_storage.HistoryStorageClient[model.Key, model.Transition], profiles generic_storage.StorageClient[string, model.Profile], logger *slog.Logger) (*Service, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("configuration cannot be nil")
 	}
@@ -20,28 +23,51 @@ func New(cfg *Config, storage generic_storage.StorageClient[model.Key, model.Cir
 		return nil, fmt.Errorf("storage cannot be nil")
 	}
 
+	if history == nil {
+		return nil, fmt.Errorf("history storage cannot be nil")
+	}
+
+	if profiles == nil {
+		return nil, fmt.Errorf("profile storage cannot be nil")
+	}
+
 	if logger == nil {
 		return nil, fmt.Errorf("logger cannot be nil")
 	}
 
+	sched, err := scheduler.New(storage, history, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize scheduler: %w", err)
+	}
+
+	service := &Service{
+		options:   options,
+		storage:   storage,
+		history:   history,
+		profiles:  profiles,
+		scheduler: sched,
+		logger:    logger,
+	}
+
 	// Initialize Gin engine
 	engine := gin.Default()
 
 	// Add middlewares
 	engine.Use(loggingMiddleware(logger))
 	engine.Use(authMiddlewareWithToken(cfg.AuthKey))
+	engine.Use(serviceMiddleware(service))
 
 	// Register routes
 	registerRoutes(engine)
 
-	return &Service{
-		storage: storage,
-		logger:  logger,
-		engine:  engine,
-	}, nil
+	service.engine = engine
+
+	return service, nil
 }
 
-func (s *Service) Run(cfg *Config) error {
+// Run serves the HTTP API until ctx is cancelled, then shuts the server down gracefully and stops the scheduler.
+// The storages are not used by the service once Run returns, so they may be shut down.
+func (s *Service) Run(ctx context.Context, cfg *Config) error {
 	if cfg == nil {
 		return fmt.Errorf("configuration cannot be nil")
 	}
@@ -50,6 +76,22 @@ func (s *Service) Run(cfg *Config) error {
 		return fmt.Errorf("gin engine is not initialized")
 	}
 
+	schedulerCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	if err := s.scheduler.Load(schedulerCtx); err != nil {
+		return fmt.Errorf("failed to load scheduler deadlines: %w", err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.scheduler.Run(schedulerCtx)
+	}()
+
 	address := fmt.Sprintf("%s:%d", cfg.ServerHost, cfg.ServerPort)
 	s.logger.Info("Starting server", "address", address)
 
@@ -61,19 +103,24 @@ func (s *Service) Run(cfg *Config) error {
 		IdleTimeout:  cfg.IdleTimeout,
 	}
 
-	// Graceful shutdown
+	serveErr := make(chan error, 1)
 	go func() {
-		<-context.Background().Done()
-		ctx, cancel := context.WithTimeout(context.Background(), cfg.GracefulTimeout)
-		defer cancel()
-
-		if err := server.Shutdown(ctx); err != nil {
-			s.logger.Error("Server shutdown failed", "error", err)
-		}
+		serveErr <- server.ListenAndServe()
 	}()
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	select {
+	case err := <-serveErr:
 		return fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Graceful shutdown
+	s.logger.Info("Shutting down server")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.GracefulTimeout)
+	defer cancelShutdown()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("server shutdown failed: %w", err)
 	}
 
 	return nil
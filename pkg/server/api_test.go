@@ -0,0 +1,241 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/bolt_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/file_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_history_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/map_test_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/server"
+)
+
+const authKey = "test-auth-key"
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// start runs the service on a free port until the returned stop is called, stop returns the error of Run.
+func start(
+	t *testing.T,
+	options server.Options,
+	storage generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry],
+	history generic_history_storage.HistoryStorageClient[model.Key, model.Transition],
+	profiles generic_storage.StorageClient[string, model.Profile],
+) (string, func() error) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cfg := &server.Config{ServerHost: "127.0.0.1", ServerPort: port, GracefulTimeout: time.Second, AuthKey: authKey}
+	service, err := server.New(cfg, options, storage, history, profiles, logger)
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- service.Run(ctx, cfg)
+	}()
+
+	address := net.JoinHostPort(cfg.ServerHost, strconv.Itoa(cfg.ServerPort))
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the server to listen, but got: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stop := func() error {
+		cancel()
+		select {
+		case err := <-runErr:
+			return err
+		case <-time.After(5 * time.Second):
+			return fmt.Errorf("run did not return after the context was cancelled")
+		}
+	}
+	return "http://" + address, stop
+}
+
+// do sends the authorized request and decodes the response into out, if it is not nil, returns the status code.
+func do(t *testing.T, method string, url string, body string, out any) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+authKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("Expected a JSON response, but got: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// startWithFileStorage runs the service on the file storages in a temporary directory, they are shut down on cleanup.
+func startWithFileStorage(t *testing.T, options server.Options) (string, generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry]) {
+	t.Helper()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage, _ := file_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+	history, _ := file_storage.NewHistory(cfg, "history", logger)
+	profiles, _ := file_storage.New[string, model.Profile](cfg, "profiles", logger)
+	url, stop := start(t, options, storage, history, profiles)
+	t.Cleanup(func() {
+		_ = stop()
+		_ = storage.Shutdown(context.Background())
+		_ = history.Shutdown(context.Background())
+		_ = profiles.Shutdown(context.Background())
+	})
+	return url, storage
+}
+
+// startWithMapStorage runs the service on the in-memory storages.
+func startWithMapStorage(t *testing.T, options server.Options) (string, generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry]) {
+	t.Helper()
+	storage, _ := map_test_storage.New[model.Key, model.CircuitBreakerEntry](logger)
+	history, _ := map_test_storage.NewHistory(logger)
+	profiles, _ := map_test_storage.New[string, model.Profile](logger)
+	url, stop := start(t, options, storage, history, profiles)
+	t.Cleanup(func() { _ = stop() })
+	return url, storage
+}
+
+func TestRunStopsGracefullyAndFileStorageReopens(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	storage, _ := file_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+	history, _ := file_storage.NewHistory(cfg, "history", logger)
+	profiles, _ := file_storage.New[string, model.Profile](cfg, "profiles", logger)
+	url, stop := start(t, server.Options{}, storage, history, profiles)
+	if status := do(t, http.MethodPut, url+"/circuit-breaker/7/config", `{"errorsThreshold": 40}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+
+	// Act
+	runErr := stop()
+	shutdownErr := storage.Shutdown(ctx)
+	_ = history.Shutdown(ctx)
+	_ = profiles.Shutdown(ctx)
+	reopened, reopenErr := file_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+
+	// Assert
+	if runErr != nil || shutdownErr != nil || reopenErr != nil {
+		t.Fatalf("Expected no errors, but got: %v, %v, %v", runErr, shutdownErr, reopenErr)
+	}
+	if info, err := os.Stat(filepath.Join(cfg.Dir, "entries.wal")); err != nil || info.Size() != 0 {
+		t.Fatalf("Expected the WAL to be compacted on the clean stop, but got: %v, %v", info, err)
+	}
+	if entry, err := reopened.GetEntry(ctx, 7); err != nil || entry.ErrorsThreshold != 40 {
+		t.Fatalf("Expected the entry to be restored from the snapshot, but got: %+v, %v", entry, err)
+	}
+}
+
+func TestRunStopsGracefullyAndBoltStorageReopens(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	// the database file stays locked until Shutdown, so reopening fails after the timeout otherwise
+	cfg := &bolt_storage.Config{Dir: t.TempDir(), OpenTimeout: 100 * time.Millisecond}
+	storage, _ := bolt_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+	history, _ := bolt_storage.NewHistory(cfg, "history", logger)
+	profiles, _ := bolt_storage.New[string, model.Profile](cfg, "profiles", logger)
+	url, stop := start(t, server.Options{}, storage, history, profiles)
+	if status := do(t, http.MethodPut, url+"/circuit-breaker/7/config", `{"errorsThreshold": 40}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+
+	// Act
+	runErr := stop()
+	shutdownErr := storage.Shutdown(ctx)
+	_ = history.Shutdown(ctx)
+	_ = profiles.Shutdown(ctx)
+	reopened, reopenErr := bolt_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+
+	// Assert
+	if runErr != nil || shutdownErr != nil || reopenErr != nil {
+		t.Fatalf("Expected no errors, but got: %v, %v, %v", runErr, shutdownErr, reopenErr)
+	}
+	defer reopened.Shutdown(ctx)
+	if entry, err := reopened.GetEntry(ctx, 7); err != nil || entry.ErrorsThreshold != 40 {
+		t.Fatalf("Expected the entry to be restored, but got: %+v, %v", entry, err)
+	}
+}
+
+func TestUpdateConfigKeepsExplicitZero(t *testing.T) {
+	// Arrange
+	defaults := model.BreakerConfig{ErrorsThreshold: 50, RampUpDurationMs: 30000, SlowCallRateThreshold: 50}
+	url, _ := startWithFileStorage(t, server.Options{Defaults: defaults})
+
+	// Act
+	var resp model.ConfigUpdateResponse
+	status := do(t, http.MethodPut, url+"/circuit-breaker/7/config", `{"rampUpDurationMs": 0, "slowCallRateThreshold": 0}`, &resp)
+
+	// Assert
+	if status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+	if resp.Config.RampUpDurationMs != 0 || resp.Config.SlowCallRateThreshold != 0 {
+		t.Fatalf("Expected the ramp-up and the slow call threshold to be disabled, but got: %+v", resp.Config)
+	}
+	if resp.Config.ErrorsThreshold != 50 {
+		t.Fatalf("Expected the unset errorsThreshold to be taken from the defaults, but got: %+v", resp.Config)
+	}
+}
+
+func TestAcquireStoresOnlyChangedEntry(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	url, storage := startWithMapStorage(t, server.Options{})
+	if status := do(t, http.MethodPut, url+"/circuit-breaker/1/config", `{"errorsThreshold": 50}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+	if status := do(t, http.MethodPut, url+"/circuit-breaker/2/config", `{"errorsThreshold": 50, "maxConcurrentCalls": 5}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+
+	// Act
+	var unlimited, limited model.AcquireResponse
+	unlimitedStatus := do(t, http.MethodPost, url+"/circuit-breaker/1/acquire", "", &unlimited)
+	limitedStatus := do(t, http.MethodPost, url+"/circuit-breaker/2/acquire", "", &limited)
+	unlimitedEntry, _ := storage.GetEntry(ctx, 1)
+	limitedEntry, _ := storage.GetEntry(ctx, 2)
+
+	// Assert
+	if unlimitedStatus != http.StatusOK || !unlimited.Allowed || unlimitedEntry.Version != 1 {
+		t.Fatalf("Expected the call to be allowed without storing the entry, but got: %d, %+v, version %d",
+			unlimitedStatus, unlimited, unlimitedEntry.Version)
+	}
+	if limitedStatus != http.StatusOK || limited.PermitID == "" || limitedEntry.Version != 2 || len(limitedEntry.Permits) != 1 {
+		t.Fatalf("Expected the permit to be stored, but got: %d, %+v, version %d", limitedStatus, limited, limitedEntry.Version)
+	}
+}
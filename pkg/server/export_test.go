@@ -0,0 +1,8 @@
+package server
+
+// The internals exposed to the server_test package.
+
+var (
+	EncodeCursor = encodeCursor
+	DecodeCursor = decodeCursor
+)
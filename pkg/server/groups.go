@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/state_machine"
+)
+
+// errNestedGroups is returned when the parent would belong to a group itself or the member would have members.
+var errNestedGroups = errors.New("nested groups are not supported")
+
+// errAlreadyGrouped is returned when the member already belongs to the group of another parent.
+var errAlreadyGrouped = errors.New("device already belongs to another group")
+
+// checkGroup returns errNestedGroups or errAlreadyGrouped if the member cannot join the group of the parent.
+// The groups are not nested, so a parent open state never cascades further.
+func checkGroup(parent *model.CircuitBreakerEntry, member *model.CircuitBreakerEntry) error {
+	if parent.ParentID != nil || len(member.Members) > 0 {
+		return errNestedGroups
+	}
+
+	if member.ParentID != nil && *member.ParentID != parent.DeviceID {
+		return errAlreadyGrouped
+	}
+
+	return nil
+}
+
+// joinGroup links the member and the parent, returns the updated parent.
+// The member is updated first, the update is undone if the parent cannot be updated,
+// so the member is never left pointing to a parent which does not list it.
+func (s *Service) joinGroup(ctx context.Context, parentID model.Key, memberID model.Key) (model.CircuitBreakerEntry, error) {
+	var joined bool
+	_, err := s.updateEntry(ctx, memberID, s.storage.GetEntry,
+		func(member *model.CircuitBreakerEntry) error {
+			if len(member.Members) > 0 {
+				return errNestedGroups
+			}
+			if member.ParentID != nil && *member.ParentID != parentID {
+				return errAlreadyGrouped
+			}
+			joined = member.ParentID == nil
+			member.ParentID = &parentID
+			return nil
+		})
+	if err != nil {
+		return model.CircuitBreakerEntry{}, fmt.Errorf("failed to update member entry: %w", err)
+	}
+
+	parent, err := s.updateEntry(ctx, parentID, s.storage.GetEntry,
+		func(parent *model.CircuitBreakerEntry) error {
+			if parent.ParentID != nil {
+				return errNestedGroups
+			}
+			if !slices.Contains(parent.Members, memberID) {
+				parent.Members = append(parent.Members, memberID)
+			}
+			return nil
+		})
+	if err == nil {
+		return parent, nil
+	}
+
+	// the member which was in the group already is left as is, the parent lists it
+	if joined {
+		_, undoErr := s.updateEntry(ctx, memberID, s.storage.GetEntry,
+			func(member *model.CircuitBreakerEntry) error {
+				if member.ParentID != nil && *member.ParentID == parentID {
+					member.ParentID = nil
+				}
+				return nil
+			})
+		if undoErr != nil && !errors.Is(undoErr, generic_storage.ErrEntryNotFound) {
+			s.logger.Error("Failed to undo member entry update", "deviceID", memberID, "parentID", parentID, "error", undoErr)
+		}
+	}
+
+	return model.CircuitBreakerEntry{}, fmt.Errorf("failed to update parent entry: %w", err)
+}
+
+// parentOf loads the parent breaker of the group the entry belongs to, nil if there is none.
+// A removed parent is treated as no parent, the member should not stay unavailable forever.
+func (s *Service) parentOf(ctx context.Context, entry *model.CircuitBreakerEntry) (*model.CircuitBreakerEntry, error) {
+	if entry.ParentID == nil {
+		return nil, nil
+	}
+
+	parent, err := s.storage.GetEntry(ctx, *entry.ParentID)
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		s.logger.Warn("Parent breaker not found", "deviceID", entry.DeviceID, "parentID", *entry.ParentID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parent entry: %w", err)
+	}
+
+	return &parent, nil
+}
+
+// statusOf builds the status of the circuit breaker, parent is nil if the device belongs to no group.
+func statusOf(entry *model.CircuitBreakerEntry, parent *model.CircuitBreakerEntry, now time.Time) model.StatusResponse {
+	stats := state_machine.Stats(entry, now)
+	errorRateThreshold, _ := state_machine.ErrorRateThreshold(entry, stats)
+
+	var baseline *model.Baseline
+	if entry.ThresholdMode == model.ThresholdModeAdaptive {
+		baseline = &entry.Baseline
+	}
+
+	return model.StatusResponse{
+		DeviceID:     strconv.FormatUint(uint64(entry.DeviceID), 10),
+		State:        state_machine.GroupState(entry, parent, now),
+		LastChanged:  entry.LastChanged,
+		CallsCnt:     stats.CallsCnt,
+		FailuresCnt:  stats.FailuresCnt,
+		ErrorRate:    stats.ErrorRate(),
+		SlowCallsCnt: stats.SlowCallsCnt,
+		SlowCallRate: stats.SlowCallRate(),
+		RetriesCnt:   stats.RetriesCnt,
+		MinimumCalls: entry.MinimumCalls,
+
+		ErrorRateThreshold: errorRateThreshold,
+		RampUpPercentage:   state_machine.RampUpPercentage(entry, now),
+		Baseline:           baseline,
+
+		FailureCategoriesCnt: entry.FailureCategoriesCnt,
+
+		ConsecutiveTripsCnt:   entry.ConsecutiveTripsCnt,
+		CurrentResetTimeoutMs: state_machine.ResetTimeoutMs(entry),
+
+		InFlightCallsCnt:   state_machine.InFlightCalls(entry, now),
+		MaxConcurrentCalls: entry.MaxConcurrentCalls,
+		RejectedCallsCnt:   entry.RejectedCallsCnt,
+
+		Override: state_machine.ActiveOverride(entry, now),
+		ParentID: entry.ParentID,
+		Profile:  entry.Profile,
+	}
+}
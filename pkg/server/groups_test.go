@@ -0,0 +1,113 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/file_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/server"
+)
+
+// failingStorage fails every write of the entry with failingKey.
+type failingStorage struct {
+	generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry]
+	failingKey model.Key
+}
+
+func (s *failingStorage) CompareAndSwap(ctx context.Context, primaryKey model.Key, expectedVersion uint64, entry model.CircuitBreakerEntry) error {
+	if primaryKey == s.failingKey {
+		return errors.New("storage is unavailable")
+	}
+	return s.StorageClient.CompareAndSwap(ctx, primaryKey, expectedVersion, entry)
+}
+
+func addDevices(t *testing.T, url string, deviceIDs ...string) {
+	t.Helper()
+	for _, deviceID := range deviceIDs {
+		if status := do(t, http.MethodPut, url+"/circuit-breaker/"+deviceID+"/config", `{}`, nil); status != http.StatusOK {
+			t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+		}
+	}
+}
+
+func TestAddAndRemoveGroupMember(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	url, storage := startWithFileStorage(t, server.Options{})
+	addDevices(t, url, "1", "2")
+
+	// Act
+	var added, removed model.GroupMembersResponse
+	addStatus := do(t, http.MethodPut, url+"/circuit-breaker/1/group/members/2", "", &added)
+	member, _ := storage.GetEntry(ctx, 2)
+	removeStatus := do(t, http.MethodDelete, url+"/circuit-breaker/1/group/members/2", "", &removed)
+	removedMember, _ := storage.GetEntry(ctx, 2)
+
+	// Assert
+	if addStatus != http.StatusOK || !slices.Equal(added.Members, []model.Key{2}) {
+		t.Fatalf("Expected member 2 to be added, but got: %d, %+v", addStatus, added)
+	}
+	if member.ParentID == nil || *member.ParentID != 1 {
+		t.Fatalf("Expected the member to point to parent 1, but got: %v", member.ParentID)
+	}
+	if removeStatus != http.StatusOK || len(removed.Members) != 0 {
+		t.Fatalf("Expected member 2 to be removed, but got: %d, %+v", removeStatus, removed)
+	}
+	if removedMember.ParentID != nil {
+		t.Fatalf("Expected the member to have no parent, but got: %v", *removedMember.ParentID)
+	}
+}
+
+func TestAddGroupMemberRejectsNestedGroups(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	url, storage := startWithFileStorage(t, server.Options{})
+	addDevices(t, url, "1", "2", "3")
+	if status := do(t, http.MethodPut, url+"/circuit-breaker/1/group/members/2", "", nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+
+	// Act
+	memberParentStatus := do(t, http.MethodPut, url+"/circuit-breaker/3/group/members/1", "", nil)
+	groupedParentStatus := do(t, http.MethodPut, url+"/circuit-breaker/2/group/members/3", "", nil)
+	parent, _ := storage.GetEntry(ctx, 1)
+	member, _ := storage.GetEntry(ctx, 3)
+
+	// Assert
+	if memberParentStatus != http.StatusBadRequest || groupedParentStatus != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, but got: %d, %d", http.StatusBadRequest, memberParentStatus, groupedParentStatus)
+	}
+	if parent.ParentID != nil || member.ParentID != nil {
+		t.Fatalf("Expected no entries to be changed, but got parents: %v, %v", parent.ParentID, member.ParentID)
+	}
+}
+
+func TestAddGroupMemberUndoesMemberUpdateOnParentFailure(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	cfg := &file_storage.Config{Dir: t.TempDir()}
+	entries, _ := file_storage.New[model.Key, model.CircuitBreakerEntry](cfg, "entries", logger)
+	history, _ := file_storage.NewHistory(cfg, "history", logger)
+	profiles, _ := file_storage.New[string, model.Profile](cfg, "profiles", logger)
+	_ = entries.UpsertEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1})
+	_ = entries.UpsertEntry(ctx, 2, model.CircuitBreakerEntry{DeviceID: 2})
+	url, stop := start(t, server.Options{}, &failingStorage{StorageClient: entries, failingKey: 1}, history, profiles)
+	defer stop()
+
+	// Act
+	status := do(t, http.MethodPut, url+"/circuit-breaker/1/group/members/2", "", nil)
+	member, _ := entries.GetEntry(ctx, 2)
+
+	// Assert
+	if status != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusInternalServerError, status)
+	}
+	if member.ParentID != nil {
+		t.Fatalf("Expected the member update to be undone, but got parent: %v", *member.ParentID)
+	}
+}
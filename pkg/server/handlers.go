@@ -1,12 +1,18 @@
 package server
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
 	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/state_machine"
 )
 
 // updateConfig updates the configuration of a circuit breaker.
@@ -24,25 +30,214 @@ func updateConfig(c *gin.Context) {
 		return
 	}
 
-	var req model.CircuitBreakerEntry
+	var req model.ConfigUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 		return
 	}
 
-	req.DeviceID = model.Key(deviceID)
-	err = service.storage.UpsertEntry(c.Request.Context(), req.DeviceID, req)
-	if err != nil {
-		service.logger.Error("Failed to upsert entry", "deviceID", deviceID, "error", err)
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := service.setDeviceConfig(c.Request.Context(), model.Key(deviceID), req.Profile, req.OptionalBreakerConfig)
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Profile not found"})
+		return
+	} else if errors.Is(err, errInvalidConfig) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to update entry", "deviceID", deviceID, "profile", req.Profile, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update config"})
 		return
 	}
+	service.scheduler.Track(&entry)
 
-	c.JSON(http.StatusOK, gin.H{"deviceID": req.DeviceID, "config": req})
+	c.JSON(http.StatusOK, model.ConfigUpdateResponse{
+		DeviceID: deviceIDStr,
+		Config:   model.EffectiveConfig{Profile: entry.Profile, BreakerConfig: entry.BreakerConfig},
+	})
 }
 
-// resetCircuitBreaker resets a circuit breaker to the CLOSED state.
-func resetCircuitBreaker(c *gin.Context) {
+// reportFailure registers a failed call to the device and updates the circuit breaker state.
+func reportFailure(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	var req model.ReportFailureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	now := time.Now()
+	call := state_machine.Call{
+		Failed:        true,
+		FailureReason: req.FailureReason,
+		ErrorCode:     req.ErrorCode,
+		PermitID:      req.PermitID,
+		Duration:      time.Duration(req.DurationMs) * time.Millisecond,
+		Retry:         req.Retry,
+	}
+	var parent *model.CircuitBreakerEntry
+	var transition model.Transition
+	var changed bool
+	entry, err := service.updateEntry(c.Request.Context(), model.Key(deviceID), service.getOrProvisionEntry,
+		func(entry *model.CircuitBreakerEntry) error {
+			var err error
+			if parent, err = service.parentOf(c.Request.Context(), entry); err != nil {
+				return err
+			}
+			transition, changed = state_machine.Report(entry, call, now)
+			return nil
+		})
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to report failure", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report failure"})
+		return
+	}
+	service.scheduler.Track(&entry)
+	if changed {
+		service.recordTransition(c.Request.Context(), transition, "failureReason", req.FailureReason)
+	}
+
+	c.JSON(http.StatusOK, model.ReportFailureResponse{
+		DeviceID: entry.DeviceID,
+		State:    state_machine.GroupState(&entry, parent, now),
+	})
+}
+
+// reportSuccess registers a successful call to the device and updates the circuit breaker state.
+func reportSuccess(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	// the payload is optional, it is required only for the calls made with a permit
+	var req model.ReportSuccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	now := time.Now()
+	call := state_machine.Call{
+		PermitID: req.PermitID,
+		Duration: time.Duration(req.DurationMs) * time.Millisecond,
+		Retry:    req.Retry,
+	}
+	var parent *model.CircuitBreakerEntry
+	var transition model.Transition
+	var changed bool
+	entry, err := service.updateEntry(c.Request.Context(), model.Key(deviceID), service.getOrProvisionEntry,
+		func(entry *model.CircuitBreakerEntry) error {
+			var err error
+			if parent, err = service.parentOf(c.Request.Context(), entry); err != nil {
+				return err
+			}
+			transition, changed = state_machine.Report(entry, call, now)
+			return nil
+		})
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to report success", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report success"})
+		return
+	}
+	service.scheduler.Track(&entry)
+	if changed {
+		service.recordTransition(c.Request.Context(), transition)
+	}
+
+	c.JSON(http.StatusOK, model.ReportSuccessResponse{
+		DeviceID: entry.DeviceID,
+		State:    state_machine.GroupState(&entry, parent, now),
+	})
+}
+
+// acquirePermit decides whether the caller may call the device.
+func acquirePermit(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	now := time.Now()
+	var parent *model.CircuitBreakerEntry
+	var decision state_machine.Decision
+	entry, err := service.updateEntry(c.Request.Context(), model.Key(deviceID), service.getOrProvisionEntry,
+		func(entry *model.CircuitBreakerEntry) error {
+			var err error
+			if parent, err = service.parentOf(c.Request.Context(), entry); err != nil {
+				return err
+			}
+			if state_machine.ParentOpen(parent, now) {
+				decision = state_machine.Decision{Allowed: false}
+				return errUnchanged
+			}
+
+			// most calls are allowed or denied without a permit, the entry is stored only if it was changed
+			permits := slices.Clone(entry.Permits)
+			rejectedCallsCnt := entry.RejectedCallsCnt
+			decision = state_machine.Acquire(entry, now)
+			if slices.Equal(entry.Permits, permits) && entry.RejectedCallsCnt == rejectedCallsCnt {
+				return errUnchanged
+			}
+			return nil
+		})
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to acquire permit", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acquire permit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.AcquireResponse{
+		DeviceID: entry.DeviceID,
+		Allowed:  decision.Allowed,
+		PermitID: decision.PermitID,
+		State:    state_machine.GroupState(&entry, parent, now),
+	})
+}
+
+// getRetryBudget tells whether one more retry of the calls to the device fits into its retry budget.
+func getRetryBudget(c *gin.Context) {
 	service, err := getServiceSafely(c)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
@@ -63,15 +258,198 @@ func resetCircuitBreaker(c *gin.Context) {
 		return
 	}
 
-	entry.State = model.StateClosed
-	entry.LastChanged = time.Now()
+	now := time.Now()
+	stats := state_machine.Stats(&entry, now)
+	var retriesLimit *int
+	if limit, ok := state_machine.RetriesLimit(&entry, stats); ok {
+		retriesLimit = &limit
+	}
+
+	c.JSON(http.StatusOK, model.RetryBudgetResponse{
+		DeviceID:         entry.DeviceID,
+		Allowed:          state_machine.AllowRetry(&entry, now),
+		FirstAttemptsCnt: stats.CallsCnt - stats.RetriesCnt,
+		RetriesCnt:       stats.RetriesCnt,
+		RetriesLimit:     retriesLimit,
+	})
+}
+
+// releasePermit returns the permit of a call which was not made, so it is counted neither as a success nor as a failure.
+func releasePermit(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
 
-	err = service.storage.UpsertEntry(c.Request.Context(), entry.DeviceID, entry)
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	var req model.ReleaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.PermitID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	var released bool
+	entry, err := service.updateEntry(c.Request.Context(), model.Key(deviceID), service.storage.GetEntry,
+		func(entry *model.CircuitBreakerEntry) error {
+			released = state_machine.Release(entry, req.PermitID)
+			return nil
+		})
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to release permit", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release permit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ReleaseResponse{
+		DeviceID: entry.DeviceID,
+		Released: released,
+	})
+}
+
+// setOverride sets an administrative override of the circuit breaker state.
+func setOverride(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	var req model.OverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	var transition model.Transition
+	var changed bool
+	entry, err := service.updateEntry(c.Request.Context(), model.Key(deviceID), service.storage.GetEntry,
+		func(entry *model.CircuitBreakerEntry) error {
+			transition, changed = state_machine.SetOverride(entry, req.Mode, req.Reason, req.ExpiresAt, now)
+			return nil
+		})
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to set override", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set override"})
+		return
+	}
+	service.scheduler.Track(&entry)
+	if changed {
+		service.recordTransition(c.Request.Context(), transition, "reason", req.Reason)
+	}
+
+	service.logger.Info("Circuit breaker override set",
+		"deviceID", deviceID, "mode", req.Mode, "reason", req.Reason, "expiresAt", req.ExpiresAt)
+
+	c.JSON(http.StatusOK, model.OverrideResponse{
+		DeviceID: entry.DeviceID,
+		State:    state_machine.EffectiveState(&entry, now),
+		Override: state_machine.ActiveOverride(&entry, now),
+	})
+}
+
+// clearOverride removes the administrative override of the circuit breaker state.
+func clearOverride(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	var transition model.Transition
+	var changed bool
+	entry, err := service.updateEntry(c.Request.Context(), model.Key(deviceID), service.storage.GetEntry,
+		func(entry *model.CircuitBreakerEntry) error {
+			transition, changed = state_machine.ClearOverride(entry, time.Now())
+			return nil
+		})
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to clear override", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear override"})
+		return
+	}
+	service.scheduler.Track(&entry)
+	if changed {
+		service.recordTransition(c.Request.Context(), transition)
+	}
+
+	service.logger.Info("Circuit breaker override cleared", "deviceID", deviceID)
+
+	c.JSON(http.StatusOK, model.OverrideResponse{
+		DeviceID: entry.DeviceID,
+		State:    entry.State,
+	})
+}
+
+// resetCircuitBreaker resets a circuit breaker to the CLOSED state.
+func resetCircuitBreaker(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	var transition model.Transition
+	var changed bool
+	entry, err := service.updateEntry(c.Request.Context(), model.Key(deviceID), service.storage.GetEntry,
+		func(entry *model.CircuitBreakerEntry) error {
+			transition, changed = state_machine.Reset(entry, time.Now())
+			return nil
+		})
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	} else if err != nil {
 		service.logger.Error("Failed to reset entry", "deviceID", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset circuit breaker"})
 		return
 	}
+	service.scheduler.Track(&entry)
+	if changed {
+		service.recordTransition(c.Request.Context(), transition)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"deviceID": entry.DeviceID, "newState": entry.State})
 }
@@ -98,56 +476,468 @@ func getCircuitBreakerStatus(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, entry)
+	parent, err := service.parentOf(c.Request.Context(), &entry)
+	if err != nil {
+		service.logger.Error("Failed to get parent entry", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, statusOf(&entry, parent, time.Now()))
 }
 
-// getAllCircuitBreakers retrieves all circuit breakers with optional pagination.
-func getAllCircuitBreakers(c *gin.Context) {
+// addGroupMember adds the device to the group of the parent circuit breaker.
+func addGroupMember(c *gin.Context) {
 	service, err := getServiceSafely(c)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
 		return
 	}
 
-	pageStr := c.DefaultQuery("page", "1")
-	pageSizeStr := c.DefaultQuery("pageSize", "10")
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	memberIDStr := c.Param("memberID")
+	memberID, err := strconv.Atoi(memberIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid memberID"})
+		return
+	}
+
+	if memberID == deviceID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Device cannot be a member of its own group"})
+		return
+	}
+
+	parent, err := service.storage.GetEntry(c.Request.Context(), model.Key(deviceID))
+	if err != nil {
+		service.logger.Error("Failed to get entry", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	}
 
+	member, err := service.storage.GetEntry(c.Request.Context(), model.Key(memberID))
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member device not found"})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to get entry", "deviceID", memberID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add group member"})
+		return
+	}
+
+	err = checkGroup(&parent, &member)
+	if err == nil {
+		parent, err = service.joinGroup(c.Request.Context(), parent.DeviceID, member.DeviceID)
+	}
+	if errors.Is(err, errNestedGroups) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nested groups are not supported"})
+		return
+	} else if errors.Is(err, errAlreadyGrouped) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Device already belongs to another group"})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to add group member", "deviceID", deviceID, "memberID", memberID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add group member"})
+		return
+	}
+
+	service.logger.Info("Group member added", "deviceID", deviceID, "memberID", memberID)
+
+	c.JSON(http.StatusOK, model.GroupMembersResponse{
+		DeviceID: parent.DeviceID,
+		Members:  parent.Members,
+	})
+}
+
+// removeGroupMember removes the device from the group of the parent circuit breaker.
+func removeGroupMember(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	memberIDStr := c.Param("memberID")
+	memberID, err := strconv.Atoi(memberIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid memberID"})
+		return
+	}
+
+	parent, err := service.storage.GetEntry(c.Request.Context(), model.Key(deviceID))
+	if err != nil {
+		service.logger.Error("Failed to get entry", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	}
+
+	if !slices.Contains(parent.Members, model.Key(memberID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device is not a member of the group"})
+		return
+	}
+
+	// the member could be removed from storage meanwhile, the group is cleaned up anyway
+	_, err = service.updateEntry(c.Request.Context(), model.Key(memberID), service.storage.GetEntry,
+		func(member *model.CircuitBreakerEntry) error {
+			if member.ParentID != nil && *member.ParentID == parent.DeviceID {
+				member.ParentID = nil
+			}
+			return nil
+		})
+	if err != nil && !errors.Is(err, generic_storage.ErrEntryNotFound) {
+		service.logger.Error("Failed to update member entry", "deviceID", memberID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove group member"})
+		return
+	}
+
+	parent, err = service.updateEntry(c.Request.Context(), model.Key(deviceID), service.storage.GetEntry,
+		func(parent *model.CircuitBreakerEntry) error {
+			parent.Members = slices.DeleteFunc(parent.Members, func(id model.Key) bool { return id == model.Key(memberID) })
+			return nil
+		})
+	if err != nil {
+		service.logger.Error("Failed to update entry", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove group member"})
+		return
+	}
+
+	service.logger.Info("Group member removed", "deviceID", deviceID, "memberID", memberID)
+
+	c.JSON(http.StatusOK, model.GroupMembersResponse{
+		DeviceID: parent.DeviceID,
+		Members:  parent.Members,
+	})
+}
+
+// getGroupStatus retrieves the status of the parent circuit breaker and all members of its group.
+func getGroupStatus(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	parent, err := service.storage.GetEntry(c.Request.Context(), model.Key(deviceID))
+	if err != nil {
+		service.logger.Error("Failed to get entry", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	}
+
+	now := time.Now()
+	response := model.GroupStatusResponse{
+		Parent:  statusOf(&parent, nil, now),
+		Members: make([]model.StatusResponse, 0, len(parent.Members)),
+	}
+	for _, memberID := range parent.Members {
+		member, err := service.storage.GetEntry(c.Request.Context(), memberID)
+		if errors.Is(err, generic_storage.ErrEntryNotFound) {
+			service.logger.Warn("Group member not found", "deviceID", deviceID, "memberID", memberID)
+			continue
+		}
+		if err != nil {
+			service.logger.Error("Failed to get entry", "deviceID", memberID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve group status"})
+			return
+		}
+		response.Members = append(response.Members, statusOf(&member, &parent, now))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// getCircuitBreakerHistory retrieves the state transitions of a specific circuit breaker, newest first.
+func getCircuitBreakerHistory(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	deviceIDStr := c.Param("deviceID")
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deviceID"})
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
 	page, err := strconv.Atoi(pageStr)
 	if err != nil || page < 1 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page number"})
 		return
 	}
 
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page size"})
+	pageSize, err := service.pageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := service.storage.GetEntry(c.Request.Context(), model.Key(deviceID)); err != nil {
+		service.logger.Error("Failed to get entry", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	}
+
+	totalItems, err := service.history.CountRecords(c.Request.Context(), model.Key(deviceID))
+	if err != nil {
+		service.logger.Error("Failed to count history records", "deviceID", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve history"})
+		return
+	}
+
+	// the pages beyond the last one are empty, the offset of a huge page number would overflow
+	totalPages := (totalItems + pageSize - 1) / pageSize
+	transitions := []model.Transition{}
+	if page <= totalPages {
+		transitions, err = service.history.GetRecordsPaginated(c.Request.Context(), model.Key(deviceID), (page-1)*pageSize, pageSize)
+		if err != nil {
+			service.logger.Error("Failed to get history records", "deviceID", deviceID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve history"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, model.HistoryResponse{
+		DeviceID:    model.Key(deviceID),
+		Page:        page,
+		PageSize:    pageSize,
+		TotalItems:  totalItems,
+		TotalPages:  totalPages,
+		Transitions: transitions,
+	})
+}
+
+// getAllCircuitBreakers retrieves the circuit breakers ordered by the device ID, page by page.
+// The pages are requested with the cursor returned with the previous page, or by the page number.
+func getAllCircuitBreakers(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	pageSize, err := service.pageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	allEntries, err := service.storage.GetAllEntries(c.Request.Context())
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		var lastPrimaryKey *model.Key
+		if cursor != "" {
+			key, err := decodeCursor(cursor)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+			lastPrimaryKey = &key
+		}
+
+		entries, nextCursor, err := service.entriesPage(c.Request.Context(), lastPrimaryKey, pageSize)
+		if err != nil {
+			service.logger.Error("Failed to get entries page", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve circuit breakers"})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.CursorPaginatedResponse{
+			PageSize:        pageSize,
+			NextCursor:      nextCursor,
+			CircuitBreakers: entries,
+		})
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page number"})
+		return
+	}
+
+	entries, totalItems, nextCursor, err := service.numberedPage(c.Request.Context(), page, pageSize)
 	if err != nil {
-		service.logger.Error("Failed to get all entries", "error", err)
+		service.logger.Error("Failed to get entries page", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve circuit breakers"})
 		return
 	}
 
-	// Apply pagination
-	start := (page - 1) * pageSize
-	end := start + pageSize
-	if start > len(allEntries) {
-		start, end = len(allEntries), len(allEntries)
+	c.JSON(http.StatusOK, model.PaginatedResponse{
+		Page:            page,
+		PageSize:        pageSize,
+		TotalItems:      totalItems,
+		TotalPages:      (totalItems + pageSize - 1) / pageSize,
+		NextCursor:      nextCursor,
+		CircuitBreakers: entries,
+	})
+}
+
+// importCircuitBreakers creates the circuit breakers of many devices at once, the existing devices are skipped.
+func importCircuitBreakers(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
 	}
-	if end > len(allEntries) {
-		end = len(allEntries)
+
+	var req model.ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Devices) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
 	}
 
-	paginatedEntries := allEntries[start:end]
+	results, err := service.importEntries(c.Request.Context(), req.Devices)
+	if err != nil {
+		service.logger.Error("Failed to import circuit breakers", "devicesCnt", len(req.Devices), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import circuit breakers"})
+		return
+	}
+
+	importedCnt := 0
+	for _, result := range results {
+		if result.Error == "" {
+			importedCnt++
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"page":            page,
-		"pageSize":        pageSize,
-		"totalItems":      len(allEntries),
-		"totalPages":      (len(allEntries) + pageSize - 1) / pageSize,
-		"circuitBreakers": paginatedEntries,
+	service.logger.Info("Circuit breakers imported", "devicesCnt", len(req.Devices), "importedCnt", importedCnt)
+
+	c.JSON(http.StatusOK, model.ImportResponse{
+		ImportedCnt: importedCnt,
+		Results:     results,
 	})
 }
+
+// upsertProfile creates or updates a profile and applies it to all devices based on it.
+func upsertProfile(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	name := c.Param("name")
+
+	var req model.ProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, devices, err := service.storeProfile(c.Request.Context(), model.Profile{Name: name, OptionalBreakerConfig: req.OptionalBreakerConfig})
+	if errors.Is(err, errIncompatibleProfile) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to store profile", "profile", name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	if err := service.storeProfileDevices(c.Request.Context(), profile, devices); err != nil {
+		service.logger.Error("Failed to store profile devices", "profile", name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply profile to devices"})
+		return
+	}
+
+	service.logger.Info("Profile updated", "profile", name, "devicesCnt", len(devices))
+
+	c.JSON(http.StatusOK, model.ProfileUpdateResponse{
+		Profile:           profile,
+		UpdatedDevicesCnt: len(devices),
+	})
+}
+
+// getProfile retrieves a profile.
+func getProfile(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	name := c.Param("name")
+
+	profile, err := service.profiles.GetEntry(c.Request.Context(), name)
+	if err != nil {
+		service.logger.Error("Failed to get profile", "profile", name, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// removeProfile removes a profile which no device is based on.
+func removeProfile(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	name := c.Param("name")
+
+	err = service.removeProfile(c.Request.Context(), name)
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	} else if errors.Is(err, errProfileInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Profile is in use"})
+		return
+	} else if err != nil {
+		service.logger.Error("Failed to remove profile", "profile", name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove profile"})
+		return
+	}
+
+	service.logger.Info("Profile removed", "profile", name)
+
+	c.JSON(http.StatusOK, gin.H{"name": name})
+}
+
+// getAllProfiles retrieves all profiles ordered by name.
+func getAllProfiles(c *gin.Context) {
+	service, err := getServiceSafely(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service instance"})
+		return
+	}
+
+	profiles, err := service.profiles.GetAllEntries(c.Request.Context())
+	if err != nil {
+		service.logger.Error("Failed to get all profiles", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve profiles"})
+		return
+	}
+
+	slices.SortFunc(profiles, func(a, b model.Profile) int { return strings.Compare(a.Name, b.Name) })
+
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"context"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// recordTransition logs the state change and appends it to the history.
+// The entry is already stored at this point, so the failure to record the history
+// is only logged and does not fail the request.
+func (s *Service) recordTransition(ctx context.Context, transition model.Transition, args ...any) {
+	s.logger.Info("Circuit breaker state changed", append([]any{
+		"deviceID", transition.DeviceID, "from", transition.From, "to", transition.To, "trigger", transition.Trigger,
+	}, args...)...)
+
+	if err := s.history.AppendRecord(ctx, transition.DeviceID, transition); err != nil {
+		s.logger.Error("Failed to record transition", "deviceID", transition.DeviceID, "error", err)
+	}
+}
@@ -0,0 +1,36 @@
+package server_test
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/server"
+)
+
+func TestGetHistoryLimitsPageSize(t *testing.T) {
+	// Arrange
+	url, _ := startWithMapStorage(t, server.Options{DefaultPageSize: 5, MaxPageSize: 100})
+	addDevices(t, url, "1")
+	historyURL := url + "/circuit-breaker/1/history"
+
+	// Act
+	var defaulted, beyond model.HistoryResponse
+	defaultedStatus := do(t, http.MethodGet, historyURL, "", &defaulted)
+	overMaxStatus := do(t, http.MethodGet, historyURL+"?pageSize=101", "", nil)
+	maxIntStatus := do(t, http.MethodGet, historyURL+"?pageSize="+strconv.Itoa(math.MaxInt), "", nil)
+	beyondStatus := do(t, http.MethodGet, historyURL+"?pageSize=100&page="+strconv.Itoa(math.MaxInt), "", &beyond)
+
+	// Assert
+	if defaultedStatus != http.StatusOK || defaulted.PageSize != 5 {
+		t.Fatalf("Expected the default page size 5, but got: %d, %+v", defaultedStatus, defaulted)
+	}
+	if overMaxStatus != http.StatusBadRequest || maxIntStatus != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, but got: %d, %d", http.StatusBadRequest, overMaxStatus, maxIntStatus)
+	}
+	if beyondStatus != http.StatusOK || len(beyond.Transitions) != 0 {
+		t.Fatalf("Expected an empty page beyond the last one, but got: %d, %+v", beyondStatus, beyond)
+	}
+}
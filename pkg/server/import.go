@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// importEntries creates the closed circuit breakers of the devices in a single batch.
+// The devices which are invalid or already exist are skipped, the result of each device is returned.
+func (s *Service) importEntries(ctx context.Context, devices []model.DeviceConfig) ([]model.ImportResult, error) {
+	now := time.Now()
+	results := make([]model.ImportResult, len(devices))
+	batch := make([]generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry], 0, len(devices))
+	batchResults := make([]int, 0, len(devices)) // index of the result of each batch entry
+
+	for i, device := range devices {
+		results[i].DeviceID = device.DeviceID
+
+		entry, err := s.newEntry(ctx, device, now)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		batch = append(batch, generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry]{
+			PrimaryKey: device.DeviceID,
+			Entry:      entry,
+		})
+		batchResults = append(batchResults, i)
+	}
+
+	if len(batch) == 0 {
+		return results, nil
+	}
+
+	errs, err := s.storage.AddNewEntriesBatch(ctx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add entries: %w", err)
+	}
+
+	for j, err := range errs {
+		result := &results[batchResults[j]]
+		if errors.Is(err, generic_storage.ErrEntryAlreadyExists) {
+			result.Error = "device already exists"
+		} else if err != nil {
+			s.logger.Error("Failed to add entry", "deviceID", result.DeviceID, "error", err)
+			result.Error = "failed to add device"
+		}
+	}
+
+	return results, nil
+}
+
+// newEntry builds the closed circuit breaker of the device, the config is validated the same way updateConfig does.
+func (s *Service) newEntry(ctx context.Context, device model.DeviceConfig, now time.Time) (model.CircuitBreakerEntry, error) {
+	if err := device.Validate(); err != nil {
+		return model.CircuitBreakerEntry{}, err
+	}
+
+	config, _, err := s.effectiveConfig(ctx, device.Profile, device.OptionalBreakerConfig)
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		return model.CircuitBreakerEntry{}, fmt.Errorf("profile not found")
+	} else if err != nil {
+		return model.CircuitBreakerEntry{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return model.CircuitBreakerEntry{}, err
+	}
+
+	return model.CircuitBreakerEntry{
+		DeviceID:        device.DeviceID,
+		State:           model.StateClosed,
+		LastChanged:     now,
+		BreakerConfig:   config,
+		Profile:         device.Profile,
+		ConfigOverrides: device.OptionalBreakerConfig,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/server"
+)
+
+func TestImportReportsResultOfEachDevice(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	url, storage := startWithMapStorage(t, server.Options{Defaults: model.BreakerConfig{ErrorsThreshold: 50}})
+	if status := do(t, http.MethodPut, url+"/circuit-breaker/2/config", `{"errorsThreshold": 30}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+	body := `{"devices": [
+		{"deviceID": 1, "errorsThreshold": 150},
+		{"deviceID": 2, "errorsThreshold": 40},
+		{"deviceID": 3, "errorsThreshold": 40}
+	]}`
+
+	// Act
+	var resp model.ImportResponse
+	status := do(t, http.MethodPost, url+"/circuit-breakers/import", body, &resp)
+	_, invalidErr := storage.GetEntry(ctx, 1)
+	existing, _ := storage.GetEntry(ctx, 2)
+	imported, importedErr := storage.GetEntry(ctx, 3)
+
+	// Assert
+	if status != http.StatusOK || resp.ImportedCnt != 1 || len(resp.Results) != 3 {
+		t.Fatalf("Expected 1 of 3 devices to be imported, but got: %d, %+v", status, resp)
+	}
+	if resp.Results[0].DeviceID != 1 || resp.Results[0].Error == "" || invalidErr == nil {
+		t.Fatalf("Expected the invalid device to be rejected, but got: %+v, %v", resp.Results[0], invalidErr)
+	}
+	if resp.Results[1].DeviceID != 2 || resp.Results[1].Error != "device already exists" || existing.ErrorsThreshold != 30 {
+		t.Fatalf("Expected the existing device to be kept, but got: %+v, %d", resp.Results[1], existing.ErrorsThreshold)
+	}
+	if resp.Results[2].DeviceID != 3 || resp.Results[2].Error != "" || importedErr != nil || imported.ErrorsThreshold != 40 {
+		t.Fatalf("Expected the new device to be imported, but got: %+v, %+v, %v", resp.Results[2], imported, importedErr)
+	}
+}
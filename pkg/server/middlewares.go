@@ -35,3 +35,11 @@ func authMiddlewareWithToken(token string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// serviceMiddleware makes the Service instance available to the handlers, see getServiceSafely.
+func serviceMiddleware(service *Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("service", service)
+		c.Next()
+	}
+}
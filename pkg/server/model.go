@@ -6,14 +6,32 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_history_storage"
 	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
 	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/scheduler"
 )
 
 type Service struct {
-	storage generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry]
-	logger  *slog.Logger
-	engine  *gin.Engine
+	options   Options
+	storage   generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry]
+	history   generic_history_storage.HistoryStorageClient[model.Key, model.Transition]
+	profiles  generic_storage.StorageClient[string, model.Profile]
+	scheduler *scheduler.Scheduler
+	logger    *slog.Logger
+	engine    *gin.Engine
+}
+
+// Options represents the service behaviour settings which are not related to the HTTP API.
+type Options struct {
+	// Defaults are applied to the circuit breaker config fields which are not set explicitly.
+	Defaults model.BreakerConfig
+	// AutoProvision creates the unknown devices from Defaults on acquire and report, they are not found otherwise.
+	AutoProvision bool
+	// DefaultPageSize is used by the paginated endpoints if the page size is not requested, defaultPageSize if not set.
+	DefaultPageSize int
+	// MaxPageSize is the largest page size the paginated endpoints accept, defaultMaxPageSize if not set.
+	MaxPageSize int
 }
 
 type Config struct {
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+const (
+	defaultPageSize    = 10
+	defaultMaxPageSize = 1000
+)
+
+// pageSize reads the pageSize query parameter, Options.DefaultPageSize if it is missing.
+// Fails if it is not a positive number or exceeds Options.MaxPageSize, so the storages never
+// allocate or compute the offsets from an unbounded value.
+func (s *Service) pageSize(c *gin.Context) (int, error) {
+	defaultSize := s.options.DefaultPageSize
+	if defaultSize <= 0 {
+		defaultSize = defaultPageSize
+	}
+	maxSize := s.options.MaxPageSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxPageSize
+	}
+
+	pageSizeStr, ok := c.GetQuery("pageSize")
+	if !ok {
+		return min(defaultSize, maxSize), nil
+	}
+
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 {
+		return 0, fmt.Errorf("invalid page size")
+	}
+	if pageSize > maxSize {
+		return 0, fmt.Errorf("page size cannot exceed %d", maxSize)
+	}
+
+	return pageSize, nil
+}
+
+// The cursor is the last primary key of the page, encoded so the clients do not rely on its format.
+
+func encodeCursor(lastPrimaryKey model.Key) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(lastPrimaryKey), 10)))
+}
+
+func decodeCursor(cursor string) (model.Key, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode cursor: %w", err)
+	}
+
+	key, err := strconv.ParseUint(string(decoded), 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse cursor: %w", err)
+	}
+
+	return model.Key(key), nil
+}
+
+// entriesPage loads up to pageSize entries ordered by the primary key, starting right after lastPrimaryKey.
+// Returns the cursor of the next page, empty if this page is the last one.
+func (s *Service) entriesPage(ctx context.Context, lastPrimaryKey *model.Key, pageSize int) ([]model.CircuitBreakerEntry, string, error) {
+	// one more entry tells whether there is a next page
+	entries, err := s.storage.GetAllEntriesPaginated(ctx, lastPrimaryKey, pageSize+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get entries: %w", err)
+	}
+
+	if len(entries) <= pageSize {
+		return entries, "", nil
+	}
+
+	entries = entries[:pageSize]
+	return entries, encodeCursor(entries[pageSize-1].DeviceID), nil
+}
+
+// numberedPage loads the page by its number, starting from 1, along with the total number of entries.
+// Returns the cursor of the next page, empty if this page is the last one.
+// The keys are loaded for the total anyway, so the page is started right after the key preceding it
+// and only the entries of the page are loaded, however deep the page is.
+func (s *Service) numberedPage(ctx context.Context, page int, pageSize int) ([]model.CircuitBreakerEntry, int, string, error) {
+	keys, err := s.storage.GetAllPrimaryKeys(ctx)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to get primary keys: %w", err)
+	}
+
+	if page > (len(keys)+pageSize-1)/pageSize {
+		return []model.CircuitBreakerEntry{}, len(keys), "", nil
+	}
+
+	var lastPrimaryKey *model.Key
+	if page > 1 {
+		slices.Sort(keys)
+		lastPrimaryKey = &keys[(page-1)*pageSize-1]
+	}
+
+	entries, nextCursor, err := s.entriesPage(ctx, lastPrimaryKey, pageSize)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return entries, len(keys), nextCursor, nil
+}
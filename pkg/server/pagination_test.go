@@ -0,0 +1,136 @@
+package server_test
+
+import (
+	"encoding/base64"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/server"
+)
+
+func deviceIDs(entries []model.CircuitBreakerEntry) []model.Key {
+	ids := make([]model.Key, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.DeviceID)
+	}
+	return ids
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	// Act
+	key, err := server.DecodeCursor(server.EncodeCursor(42))
+
+	// Assert
+	if err != nil || key != 42 {
+		t.Fatalf("Expected key 42, but got: %d, %v", key, err)
+	}
+}
+
+func TestDecodeCursorRejectsMalformedCursor(t *testing.T) {
+	for _, cursor := range []string{"not base64!", base64.RawURLEncoding.EncodeToString([]byte("abc")), base64.RawURLEncoding.EncodeToString([]byte("-1"))} {
+		// Act
+		_, err := server.DecodeCursor(cursor)
+
+		// Assert
+		if err == nil {
+			t.Fatalf("Expected cursor %q to be rejected, but got no errors", cursor)
+		}
+	}
+}
+
+func TestGetAllCircuitBreakersByCursor(t *testing.T) {
+	// Arrange
+	url, _ := startWithMapStorage(t, server.Options{})
+	addDevices(t, url, "5", "3", "1", "4", "2")
+
+	// Act
+	var pages [][]model.Key
+	cursor := ""
+	for range 5 {
+		var resp model.CursorPaginatedResponse
+		if status := do(t, http.MethodGet, url+"/circuit-breakers/?pageSize=2&cursor="+cursor, "", &resp); status != http.StatusOK {
+			t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+		}
+		pages = append(pages, deviceIDs(resp.CircuitBreakers))
+		if cursor = resp.NextCursor; cursor == "" {
+			break
+		}
+	}
+	malformedStatus := do(t, http.MethodGet, url+"/circuit-breakers/?cursor=not-a-cursor", "", nil)
+
+	// Assert
+	expected := [][]model.Key{{1, 2}, {3, 4}, {5}}
+	if !slices.EqualFunc(pages, expected, slices.Equal) {
+		t.Fatalf("Expected pages %v, but got: %v", expected, pages)
+	}
+	if malformedStatus != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for the malformed cursor, but got: %d", http.StatusBadRequest, malformedStatus)
+	}
+}
+
+func TestGetAllCircuitBreakersByPage(t *testing.T) {
+	// Arrange
+	url, _ := startWithMapStorage(t, server.Options{})
+	addDevices(t, url, "5", "3", "1", "4", "2")
+
+	// Act
+	var middle, last, beyond model.PaginatedResponse
+	middleStatus := do(t, http.MethodGet, url+"/circuit-breakers/?page=2&pageSize=2", "", &middle)
+	lastStatus := do(t, http.MethodGet, url+"/circuit-breakers/?page=3&pageSize=2", "", &last)
+	beyondStatus := do(t, http.MethodGet, url+"/circuit-breakers/?page=4&pageSize=2", "", &beyond)
+
+	// Assert
+	if middleStatus != http.StatusOK || !slices.Equal(deviceIDs(middle.CircuitBreakers), []model.Key{3, 4}) {
+		t.Fatalf("Expected devices 3 and 4 on page 2, but got: %d, %v", middleStatus, deviceIDs(middle.CircuitBreakers))
+	}
+	if middle.TotalItems != 5 || middle.TotalPages != 3 {
+		t.Fatalf("Expected 5 items on 3 pages, but got: %d, %d", middle.TotalItems, middle.TotalPages)
+	}
+	if next, err := server.DecodeCursor(middle.NextCursor); err != nil || next != 4 {
+		t.Fatalf("Expected the next cursor after device 4, but got: %d, %v", next, err)
+	}
+	if lastStatus != http.StatusOK || !slices.Equal(deviceIDs(last.CircuitBreakers), []model.Key{5}) || last.NextCursor != "" {
+		t.Fatalf("Expected device 5 on the last page, but got: %d, %+v", lastStatus, last)
+	}
+	if beyondStatus != http.StatusOK || len(beyond.CircuitBreakers) != 0 || beyond.TotalItems != 5 {
+		t.Fatalf("Expected an empty page beyond the last one, but got: %d, %+v", beyondStatus, beyond)
+	}
+}
+
+func TestGetAllCircuitBreakersLimitsPageSize(t *testing.T) {
+	// Arrange
+	url, _ := startWithMapStorage(t, server.Options{MaxPageSize: 100})
+	addDevices(t, url, "1", "2")
+
+	// Act
+	var maxSized, beyond model.PaginatedResponse
+	maxSizedStatus := do(t, http.MethodGet, url+"/circuit-breakers/?page=1&pageSize=100", "", &maxSized)
+	beyondStatus := do(t, http.MethodGet, url+"/circuit-breakers/?pageSize=100&page="+strconv.Itoa(math.MaxInt), "", &beyond)
+	var statuses []int
+	for _, query := range []string{
+		"?pageSize=101",
+		"?pageSize=" + strconv.Itoa(math.MaxInt),
+		"?pageSize=0",
+		"?pageSize=-1",
+		"?cursor=&pageSize=" + strconv.Itoa(math.MaxInt),
+	} {
+		statuses = append(statuses, do(t, http.MethodGet, url+"/circuit-breakers/"+query, "", nil))
+	}
+
+	// Assert
+	if maxSizedStatus != http.StatusOK || !slices.Equal(deviceIDs(maxSized.CircuitBreakers), []model.Key{1, 2}) || maxSized.TotalPages != 1 {
+		t.Fatalf("Expected both devices on a single page, but got: %d, %+v", maxSizedStatus, maxSized)
+	}
+	if beyondStatus != http.StatusOK || len(beyond.CircuitBreakers) != 0 || beyond.TotalItems != 2 {
+		t.Fatalf("Expected an empty page beyond the last one, but got: %d, %+v", beyondStatus, beyond)
+	}
+	for _, status := range statuses {
+		if status != http.StatusBadRequest {
+			t.Fatalf("Expected status %d for every out of range page size, but got: %v", http.StatusBadRequest, statuses)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// errIncompatibleProfile is returned when the profile makes the config of a device based on it invalid.
+var errIncompatibleProfile = errors.New("profile is incompatible with the config")
+
+// errInvalidConfig is returned when the effective config of a device is invalid.
+var errInvalidConfig = errors.New("invalid config")
+
+// errProfileInUse is returned when the profile to remove is still used by the devices.
+var errProfileInUse = errors.New("profile is in use")
+
+// effectiveConfig applies the device overrides on top of the profile and the service defaults.
+// Returns the profile it is based on, empty if profileName is empty.
+// Returns generic_storage.ErrEntryNotFound if the profile does not exist.
+func (s *Service) effectiveConfig(ctx context.Context, profileName string, overrides model.OptionalBreakerConfig) (model.BreakerConfig, model.Profile, error) {
+	if profileName == "" {
+		return overrides.ApplyTo(s.options.Defaults), model.Profile{}, nil
+	}
+
+	profile, err := s.profiles.GetEntry(ctx, profileName)
+	if err != nil {
+		return model.BreakerConfig{}, model.Profile{}, fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	return overrides.ApplyTo(profile.ApplyTo(s.options.Defaults)), profile, nil
+}
+
+// setDeviceConfig stores the config of the device based on the profile and the overrides, returns the stored entry.
+// The profile is resolved on each update attempt. A profile update stored meanwhile could miss the device, which was
+// not based on it yet, so the profile is read back and the config is resolved once again until the versions match.
+// The profile removed meanwhile is restored, so the device is never left based on a missing profile.
+// Returns generic_storage.ErrEntryNotFound if the profile does not exist and errInvalidConfig if the config is invalid.
+func (s *Service) setDeviceConfig(ctx context.Context, deviceID model.Key, profileName string, overrides model.OptionalBreakerConfig) (model.CircuitBreakerEntry, error) {
+	for attempt := 1; attempt <= maxUpdateAttempts; attempt++ {
+		var profile model.Profile
+		entry, err := s.updateEntry(ctx, deviceID, s.getOrNewEntry, func(entry *model.CircuitBreakerEntry) error {
+			var config model.BreakerConfig
+			var err error
+			if config, profile, err = s.effectiveConfig(ctx, profileName, overrides); err != nil {
+				return err
+			}
+			if err := config.Validate(); err != nil {
+				return fmt.Errorf("%w: %v", errInvalidConfig, err)
+			}
+
+			entry.BreakerConfig = config
+			entry.Profile = profileName
+			entry.ConfigOverrides = overrides
+			return nil
+		})
+		if err != nil || profileName == "" {
+			return entry, err
+		}
+
+		stored, err := s.profiles.GetEntry(ctx, profileName)
+		if errors.Is(err, generic_storage.ErrEntryNotFound) {
+			s.logger.Warn("Profile removed concurrently, restoring", "deviceID", deviceID, "profile", profileName)
+			if err := s.profiles.CompareAndSwap(ctx, profileName, 0, profile); err != nil && !errors.Is(err, generic_storage.ErrConflict) {
+				return model.CircuitBreakerEntry{}, fmt.Errorf("failed to restore profile: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return model.CircuitBreakerEntry{}, fmt.Errorf("failed to get profile: %w", err)
+		}
+		if stored.Version == profile.Version {
+			return entry, nil
+		}
+		s.logger.Debug("Profile changed concurrently, retrying", "deviceID", deviceID, "profile", profileName, "attempt", attempt)
+	}
+
+	return model.CircuitBreakerEntry{}, fmt.Errorf("failed to apply profile after %d attempts: %w", maxUpdateAttempts, generic_storage.ErrConflict)
+}
+
+// storeProfile stores the profile with CompareAndSwap on the version it replaces and returns the devices based on it
+// with the profile config applied, to be stored with storeProfileDevices.
+// The devices are loaded and checked once again if the profile was changed concurrently.
+// Returns errIncompatibleProfile if the profile makes the config of any device invalid.
+func (s *Service) storeProfile(ctx context.Context, profile model.Profile) (model.Profile, []model.CircuitBreakerEntry, error) {
+	for attempt := 1; attempt <= maxUpdateAttempts; attempt++ {
+		var expectedVersion uint64
+		stored, err := s.profiles.GetEntry(ctx, profile.Name)
+		if err == nil {
+			expectedVersion = stored.Version
+		} else if !errors.Is(err, generic_storage.ErrEntryNotFound) {
+			return model.Profile{}, nil, fmt.Errorf("failed to get profile: %w", err)
+		}
+
+		devices, err := s.profileDevices(ctx, profile)
+		if err != nil {
+			return model.Profile{}, nil, err
+		}
+
+		err = s.profiles.CompareAndSwap(ctx, profile.Name, expectedVersion, profile)
+		if errors.Is(err, generic_storage.ErrConflict) {
+			s.logger.Debug("Profile changed concurrently, retrying", "profile", profile.Name, "attempt", attempt)
+			continue
+		}
+		if err != nil {
+			return model.Profile{}, nil, fmt.Errorf("failed to store profile: %w", err)
+		}
+
+		profile.Version = expectedVersion + 1
+		return profile, devices, nil
+	}
+
+	return model.Profile{}, nil, fmt.Errorf("failed to store profile after %d attempts: %w", maxUpdateAttempts, generic_storage.ErrConflict)
+}
+
+// removeProfile removes the profile which no device is based on.
+// The devices are checked once again after the removal, the profile is restored if any device was based on it meanwhile.
+// Returns generic_storage.ErrEntryNotFound if the profile does not exist and errProfileInUse if it is still used.
+func (s *Service) removeProfile(ctx context.Context, name string) error {
+	profile, err := s.profiles.GetEntry(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	inUse, err := s.profileInUse(ctx, name)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errProfileInUse
+	}
+
+	if err := s.profiles.RemoveEntry(ctx, name); err != nil {
+		return fmt.Errorf("failed to remove profile: %w", err)
+	}
+
+	inUse, err = s.profileInUse(ctx, name)
+	if err == nil && !inUse {
+		return nil
+	}
+
+	s.logger.Warn("Profile used concurrently, restoring", "profile", name)
+	if restoreErr := s.profiles.CompareAndSwap(ctx, name, 0, profile); restoreErr != nil && !errors.Is(restoreErr, generic_storage.ErrConflict) {
+		return fmt.Errorf("failed to restore profile: %w", restoreErr)
+	}
+	if err != nil {
+		return err
+	}
+	return errProfileInUse
+}
+
+// profileInUse tells whether any device is based on the profile.
+func (s *Service) profileInUse(ctx context.Context, name string) (bool, error) {
+	entries, err := s.storage.GetAllEntries(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get entries: %w", err)
+	}
+
+	return slices.ContainsFunc(entries, func(entry model.CircuitBreakerEntry) bool { return entry.Profile == name }), nil
+}
+
+// profileDevices returns the devices based on the profile with the profile config applied.
+// Nothing is stored, so the profile can be rejected if it is incompatible with the overrides of any device.
+func (s *Service) profileDevices(ctx context.Context, profile model.Profile) ([]model.CircuitBreakerEntry, error) {
+	entries, err := s.storage.GetAllEntries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entries: %w", err)
+	}
+
+	var devices []model.CircuitBreakerEntry
+	for _, entry := range entries {
+		if entry.Profile != profile.Name {
+			continue
+		}
+
+		if err := s.applyProfile(&entry, profile); err != nil {
+			return nil, err
+		}
+		devices = append(devices, entry)
+	}
+
+	return devices, nil
+}
+
+// applyProfile recomputes the effective config of the device based on the profile.
+// Returns errIncompatibleProfile if the resulting config is invalid.
+func (s *Service) applyProfile(entry *model.CircuitBreakerEntry, profile model.Profile) error {
+	config := entry.ConfigOverrides.ApplyTo(profile.ApplyTo(s.options.Defaults))
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("%w of device %d: %v", errIncompatibleProfile, entry.DeviceID, err)
+	}
+
+	entry.BreakerConfig = config
+	return nil
+}
+
+// storeProfileDevices stores the devices returned by profileDevices in a single batch.
+// The devices changed since they were loaded are updated one by one with the profile stored at that moment,
+// which may be newer than the given one already.
+func (s *Service) storeProfileDevices(ctx context.Context, profile model.Profile, devices []model.CircuitBreakerEntry) error {
+	batch := make([]generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry], 0, len(devices))
+	for _, device := range devices {
+		batch = append(batch, generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry]{
+			PrimaryKey:      device.DeviceID,
+			ExpectedVersion: device.Version,
+			Entry:           device,
+		})
+	}
+
+	results, err := s.storage.CompareAndSwapBatch(ctx, batch)
+	if err != nil {
+		return fmt.Errorf("failed to store entries: %w", err)
+	}
+
+	for i, err := range results {
+		device := devices[i]
+		if errors.Is(err, generic_storage.ErrConflict) {
+			device, err = s.updateEntry(ctx, device.DeviceID, s.storage.GetEntry, func(entry *model.CircuitBreakerEntry) error {
+				if entry.Profile != profile.Name {
+					return nil
+				}
+				current, err := s.profiles.GetEntry(ctx, profile.Name)
+				if errors.Is(err, generic_storage.ErrEntryNotFound) {
+					return nil
+				} else if err != nil {
+					return fmt.Errorf("failed to get profile: %w", err)
+				}
+				return s.applyProfile(entry, current)
+			})
+		}
+		if errors.Is(err, generic_storage.ErrEntryNotFound) {
+			// the device was removed meanwhile
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update entry of device %d: %w", devices[i].DeviceID, err)
+		}
+		s.scheduler.Track(&device)
+	}
+
+	return nil
+}
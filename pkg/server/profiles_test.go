@@ -0,0 +1,215 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/map_test_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/server"
+)
+
+// hookedProfiles runs the hook set with onNextGet after the next GetEntry and the one set with onNextRemove
+// before the next RemoveEntry, so the concurrent changes are made at the exact moment.
+type hookedProfiles struct {
+	generic_storage.StorageClient[string, model.Profile]
+	mu           sync.Mutex
+	afterGet     func()
+	beforeRemove func()
+}
+
+func (s *hookedProfiles) onNextGet(hook func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.afterGet = hook
+}
+
+func (s *hookedProfiles) onNextRemove(hook func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.beforeRemove = hook
+}
+
+func (s *hookedProfiles) takeHook(hook *func()) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	taken := *hook
+	*hook = nil
+	return taken
+}
+
+func (s *hookedProfiles) GetEntry(ctx context.Context, primaryKey string) (model.Profile, error) {
+	profile, err := s.StorageClient.GetEntry(ctx, primaryKey)
+	if hook := s.takeHook(&s.afterGet); hook != nil {
+		hook()
+	}
+	return profile, err
+}
+
+func (s *hookedProfiles) RemoveEntry(ctx context.Context, primaryKey string) error {
+	if hook := s.takeHook(&s.beforeRemove); hook != nil {
+		hook()
+	}
+	return s.StorageClient.RemoveEntry(ctx, primaryKey)
+}
+
+func startWithHookedProfiles(t *testing.T) (string, generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry], *hookedProfiles) {
+	t.Helper()
+	storage, _ := map_test_storage.New[model.Key, model.CircuitBreakerEntry](logger)
+	history, _ := map_test_storage.NewHistory(logger)
+	profileStorage, _ := map_test_storage.New[string, model.Profile](logger)
+	profiles := &hookedProfiles{StorageClient: profileStorage}
+	url, stop := start(t, server.Options{}, storage, history, profiles)
+	t.Cleanup(func() { _ = stop() })
+	return url, storage, profiles
+}
+
+func TestProfileCRUD(t *testing.T) {
+	// Arrange
+	url, _ := startWithMapStorage(t, server.Options{})
+
+	// Act
+	var created model.ProfileUpdateResponse
+	createStatus := do(t, http.MethodPut, url+"/profiles/sensor", `{"errorsThreshold": 30}`, &created)
+	var profile model.Profile
+	getStatus := do(t, http.MethodGet, url+"/profiles/sensor", "", &profile)
+	var all struct {
+		Profiles []model.Profile `json:"profiles"`
+	}
+	listStatus := do(t, http.MethodGet, url+"/profiles/", "", &all)
+	removeStatus := do(t, http.MethodDelete, url+"/profiles/sensor", "", nil)
+	removedStatus := do(t, http.MethodGet, url+"/profiles/sensor", "", nil)
+
+	// Assert
+	if createStatus != http.StatusOK || created.Profile.Version != 1 || created.UpdatedDevicesCnt != 0 {
+		t.Fatalf("Expected the profile to be created, but got: %d, %+v", createStatus, created)
+	}
+	if getStatus != http.StatusOK || profile.ErrorsThreshold == nil || *profile.ErrorsThreshold != 30 {
+		t.Fatalf("Expected the stored profile, but got: %d, %+v", getStatus, profile)
+	}
+	if profile.ResetTimeoutMs != nil {
+		t.Fatalf("Expected the unset fields to stay unset, but got resetTimeoutMs: %d", *profile.ResetTimeoutMs)
+	}
+	if listStatus != http.StatusOK || len(all.Profiles) != 1 || all.Profiles[0].Name != "sensor" {
+		t.Fatalf("Expected the profile to be listed, but got: %d, %+v", listStatus, all)
+	}
+	if removeStatus != http.StatusOK || removedStatus != http.StatusNotFound {
+		t.Fatalf("Expected the profile to be removed, but got: %d, %d", removeStatus, removedStatus)
+	}
+}
+
+func TestProfileAppliedToDevices(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	url, storage := startWithMapStorage(t, server.Options{Defaults: model.BreakerConfig{ErrorsThreshold: 50, ResetTimeoutMs: 1000}})
+	if status := do(t, http.MethodPut, url+"/profiles/sensor", `{"errorsThreshold": 30, "resetTimeoutMs": 5000}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+	if status := do(t, http.MethodPut, url+"/circuit-breaker/1/config", `{"profile": "sensor", "resetTimeoutMs": 2000}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+
+	// Act
+	var updated model.ProfileUpdateResponse
+	status := do(t, http.MethodPut, url+"/profiles/sensor", `{"errorsThreshold": 20}`, &updated)
+	entry, _ := storage.GetEntry(ctx, 1)
+	removeStatus := do(t, http.MethodDelete, url+"/profiles/sensor", "", nil)
+
+	// Assert
+	if status != http.StatusOK || updated.UpdatedDevicesCnt != 1 {
+		t.Fatalf("Expected the profile to be applied to 1 device, but got: %d, %+v", status, updated)
+	}
+	if entry.ErrorsThreshold != 20 || entry.ResetTimeoutMs != 2000 {
+		t.Fatalf("Expected the device override on top of the new profile, but got: %d, %d",
+			entry.ErrorsThreshold, entry.ResetTimeoutMs)
+	}
+	if removeStatus != http.StatusConflict {
+		t.Fatalf("Expected status %d for the profile in use, but got: %d", http.StatusConflict, removeStatus)
+	}
+}
+
+func TestProfileRejectedIfIncompatible(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	url, storage := startWithMapStorage(t, server.Options{})
+	if status := do(t, http.MethodPut, url+"/profiles/sensor", `{"minimumCalls": 5}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+	config := `{"profile": "sensor", "windowType": "count", "windowSize": 5}`
+	if status := do(t, http.MethodPut, url+"/circuit-breaker/1/config", config, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+
+	// Act
+	status := do(t, http.MethodPut, url+"/profiles/sensor", `{"minimumCalls": 10}`, nil)
+	var profile model.Profile
+	_ = do(t, http.MethodGet, url+"/profiles/sensor", "", &profile)
+	entry, _ := storage.GetEntry(ctx, 1)
+
+	// Assert
+	if status != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusBadRequest, status)
+	}
+	if profile.MinimumCalls == nil || *profile.MinimumCalls != 5 || entry.MinimumCalls != 5 {
+		t.Fatalf("Expected the profile and the device to stay unchanged, but got: %+v, %d", profile, entry.MinimumCalls)
+	}
+}
+
+func TestUpdateConfigAppliesProfileChangedConcurrently(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	url, storage, profiles := startWithHookedProfiles(t)
+	if status := do(t, http.MethodPut, url+"/profiles/sensor", `{"errorsThreshold": 30}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+	// the profile update which loaded the devices before the device was based on the profile
+	errorsThreshold := 20
+	profiles.onNextGet(func() {
+		_ = profiles.UpsertEntry(ctx, "sensor", model.Profile{
+			Name:                  "sensor",
+			OptionalBreakerConfig: model.OptionalBreakerConfig{ErrorsThreshold: &errorsThreshold},
+		})
+	})
+
+	// Act
+	var resp model.ConfigUpdateResponse
+	status := do(t, http.MethodPut, url+"/circuit-breaker/1/config", `{"profile": "sensor"}`, &resp)
+	entry, _ := storage.GetEntry(ctx, 1)
+
+	// Assert
+	if status != http.StatusOK || resp.Config.ErrorsThreshold != 20 {
+		t.Fatalf("Expected the new profile config in the response, but got: %d, %+v", status, resp)
+	}
+	if entry.ErrorsThreshold != 20 {
+		t.Fatalf("Expected the device to be based on the new profile, but got errorsThreshold: %d", entry.ErrorsThreshold)
+	}
+}
+
+func TestRemoveProfileRestoresProfileUsedConcurrently(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	url, storage, profiles := startWithHookedProfiles(t)
+	if status := do(t, http.MethodPut, url+"/profiles/sensor", `{"errorsThreshold": 30}`, nil); status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+	// the device based on the profile after it was checked to be unused
+	profiles.onNextRemove(func() {
+		_ = storage.UpsertEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1, Profile: "sensor"})
+	})
+
+	// Act
+	status := do(t, http.MethodDelete, url+"/profiles/sensor", "", nil)
+	var profile model.Profile
+	getStatus := do(t, http.MethodGet, url+"/profiles/sensor", "", &profile)
+
+	// Assert
+	if status != http.StatusConflict {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusConflict, status)
+	}
+	if getStatus != http.StatusOK || profile.ErrorsThreshold == nil || *profile.ErrorsThreshold != 30 {
+		t.Fatalf("Expected the profile to be restored, but got: %d, %+v", getStatus, profile)
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// getOrProvisionEntry returns the entry of the device, the unknown device is created from the defaults
+// if Options.AutoProvision is set. Returns generic_storage.ErrEntryNotFound for the unknown device otherwise.
+func (s *Service) getOrProvisionEntry(ctx context.Context, deviceID model.Key) (model.CircuitBreakerEntry, error) {
+	entry, err := s.storage.GetEntry(ctx, deviceID)
+	if !errors.Is(err, generic_storage.ErrEntryNotFound) || !s.options.AutoProvision {
+		return entry, err
+	}
+
+	entry = model.CircuitBreakerEntry{
+		DeviceID:      deviceID,
+		State:         model.StateClosed,
+		LastChanged:   time.Now(),
+		BreakerConfig: s.options.Defaults,
+	}
+
+	err = s.storage.AddNewEntry(ctx, deviceID, entry)
+	if err == nil {
+		s.logger.Info("Device auto-provisioned", "deviceID", deviceID)
+	} else if !errors.Is(err, generic_storage.ErrEntryAlreadyExists) {
+		return model.CircuitBreakerEntry{}, fmt.Errorf("failed to provision entry: %w", err)
+	}
+
+	// the stored entry carries the version assigned by the storage, the one provisioned by
+	// a concurrent request is used as is
+	return s.storage.GetEntry(ctx, deviceID)
+}
+
+// getOrNewEntry returns the entry of the device, or a new closed entry which is not stored yet for the unknown device.
+func (s *Service) getOrNewEntry(ctx context.Context, deviceID model.Key) (model.CircuitBreakerEntry, error) {
+	entry, err := s.storage.GetEntry(ctx, deviceID)
+	if errors.Is(err, generic_storage.ErrEntryNotFound) {
+		return model.CircuitBreakerEntry{
+			DeviceID:    deviceID,
+			State:       model.StateClosed,
+			LastChanged: time.Now(),
+		}, nil
+	}
+	return entry, err
+}
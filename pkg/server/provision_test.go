@@ -0,0 +1,133 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/map_test_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/server"
+)
+
+var provisionDefaults = model.BreakerConfig{
+	ErrorsThreshold:  50,
+	ResetTimeoutMs:   60000,
+	WindowType:       model.WindowTypeTime,
+	WindowLengthMs:   60000,
+	WindowBucketsCnt: 10,
+}
+
+// countingStorage counts the CompareAndSwap calls which failed with generic_storage.ErrConflict.
+type countingStorage struct {
+	generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry]
+	conflictsCnt atomic.Int32
+}
+
+func (s *countingStorage) CompareAndSwap(ctx context.Context, primaryKey model.Key, expectedVersion uint64, entry model.CircuitBreakerEntry) error {
+	err := s.StorageClient.CompareAndSwap(ctx, primaryKey, expectedVersion, entry)
+	if errors.Is(err, generic_storage.ErrConflict) {
+		s.conflictsCnt.Add(1)
+	}
+	return err
+}
+
+func TestReportProvisionsUnknownDevice(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	url, storage := startWithMapStorage(t, server.Options{Defaults: provisionDefaults, AutoProvision: true})
+
+	// Act
+	status := do(t, http.MethodPost, url+"/circuit-breaker/7/report-success", "", nil)
+	entry, err := storage.GetEntry(ctx, 7)
+
+	// Assert
+	if status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+	if err != nil || entry.State != model.StateClosed || entry.ErrorsThreshold != 50 || entry.WindowLengthMs != 60000 {
+		t.Fatalf("Expected the device to be provisioned from the defaults, but got: %+v, %v", entry, err)
+	}
+}
+
+func TestStrictModeRejectsUnknownDevice(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	url, storage := startWithMapStorage(t, server.Options{Defaults: provisionDefaults})
+
+	// Act
+	acquireStatus := do(t, http.MethodPost, url+"/circuit-breaker/7/acquire", "", nil)
+	failureStatus := do(t, http.MethodPost, url+"/circuit-breaker/7/report-failure", `{"failureReason": "timeout"}`, nil)
+	successStatus := do(t, http.MethodPost, url+"/circuit-breaker/7/report-success", "", nil)
+	_, err := storage.GetEntry(ctx, 7)
+
+	// Assert
+	if acquireStatus != http.StatusNotFound || failureStatus != http.StatusNotFound || successStatus != http.StatusNotFound {
+		t.Fatalf("Expected status %d, but got: %d, %d, %d", http.StatusNotFound, acquireStatus, failureStatus, successStatus)
+	}
+	if !errors.Is(err, generic_storage.ErrEntryNotFound) {
+		t.Fatalf("Expected the device not to be created, but got: %v", err)
+	}
+}
+
+func TestConcurrentFirstTouchProvisionsOnce(t *testing.T) {
+	// Arrange
+	url, _ := startWithMapStorage(t, server.Options{Defaults: provisionDefaults, AutoProvision: true})
+	// each request loses at most one CAS attempt per concurrent request, which fits the update attempts
+	const requestsCnt = 8
+
+	// Act
+	var wg sync.WaitGroup
+	statuses := make([]int, requestsCnt)
+	for i := range requestsCnt {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// t.Fatalf cannot be called from this goroutine, the failed request keeps the zero status
+			req, _ := http.NewRequest(http.MethodPost, url+"/circuit-breaker/7/report-success", nil)
+			req.Header.Set("Authorization", "Bearer "+authKey)
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+				statuses[i] = resp.StatusCode
+			}
+		}()
+	}
+	wg.Wait()
+	var status model.StatusResponse
+	_ = do(t, http.MethodGet, url+"/circuit-breaker/7/status", "", &status)
+
+	// Assert
+	for i, s := range statuses {
+		if s != http.StatusOK {
+			t.Fatalf("Expected status %d for request %d, but got: %d", http.StatusOK, i, s)
+		}
+	}
+	if status.CallsCnt != requestsCnt {
+		t.Fatalf("Expected all %d calls to be counted on the single provisioned entry, but got: %d", requestsCnt, status.CallsCnt)
+	}
+}
+
+func TestFirstTouchStoresProvisionedEntryWithoutConflicts(t *testing.T) {
+	// Arrange
+	entries, _ := map_test_storage.New[model.Key, model.CircuitBreakerEntry](logger)
+	history, _ := map_test_storage.NewHistory(logger)
+	profiles, _ := map_test_storage.New[string, model.Profile](logger)
+	storage := &countingStorage{StorageClient: entries}
+	url, stop := start(t, server.Options{Defaults: provisionDefaults, AutoProvision: true}, storage, history, profiles)
+	defer stop()
+
+	// Act
+	status := do(t, http.MethodPost, url+"/circuit-breaker/7/report-failure", `{"failureReason": "timeout"}`, nil)
+
+	// Assert
+	if status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got: %d", http.StatusOK, status)
+	}
+	if conflictsCnt := storage.conflictsCnt.Load(); conflictsCnt != 0 {
+		t.Fatalf("Expected no conflicts on the provisioned entry, but got: %d", conflictsCnt)
+	}
+}
@@ -6,7 +6,23 @@ import (
 
 func registerRoutes(r *gin.Engine) {
 	r.PUT("/circuit-breaker/:deviceID/config", updateConfig)
+	r.POST("/circuit-breaker/:deviceID/acquire", acquirePermit)
+	r.POST("/circuit-breaker/:deviceID/release", releasePermit)
+	r.GET("/circuit-breaker/:deviceID/retry-budget", getRetryBudget)
+	r.POST("/circuit-breaker/:deviceID/report-failure", reportFailure)
+	r.POST("/circuit-breaker/:deviceID/report-success", reportSuccess)
 	r.POST("/circuit-breaker/:deviceID/reset", resetCircuitBreaker)
+	r.PUT("/circuit-breaker/:deviceID/override", setOverride)
+	r.DELETE("/circuit-breaker/:deviceID/override", clearOverride)
 	r.GET("/circuit-breaker/:deviceID/status", getCircuitBreakerStatus)
+	r.GET("/circuit-breaker/:deviceID/history", getCircuitBreakerHistory)
+	r.GET("/circuit-breaker/:deviceID/group", getGroupStatus)
+	r.PUT("/circuit-breaker/:deviceID/group/members/:memberID", addGroupMember)
+	r.DELETE("/circuit-breaker/:deviceID/group/members/:memberID", removeGroupMember)
 	r.GET("/circuit-breakers/", getAllCircuitBreakers)
+	r.POST("/circuit-breakers/import", importCircuitBreakers)
+	r.PUT("/profiles/:name", upsertProfile)
+	r.GET("/profiles/:name", getProfile)
+	r.DELETE("/profiles/:name", removeProfile)
+	r.GET("/profiles/", getAllProfiles)
 }
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// maxUpdateAttempts limits the read-modify-write cycles of a single request losing to the concurrent updates.
+const maxUpdateAttempts = 10
+
+// errUnchanged is returned by the update of updateEntry which left the entry as is, so there is nothing to store.
+var errUnchanged = errors.New("entry is unchanged")
+
+// updateEntry loads the entry with load, applies update and stores the result with CompareAndSwap.
+// The whole cycle is repeated if the entry was changed concurrently, so update may be called several times
+// and should not have side effects besides the entry and the results it assigns on each call.
+// The errors of load and update are returned as is, the stored entry is returned on success,
+// the loaded one if update returned errUnchanged.
+func (s *Service) updateEntry(
+	ctx context.Context,
+	deviceID model.Key,
+	load func(context.Context, model.Key) (model.CircuitBreakerEntry, error),
+	update func(entry *model.CircuitBreakerEntry) error,
+) (model.CircuitBreakerEntry, error) {
+	for attempt := 1; attempt <= maxUpdateAttempts; attempt++ {
+		entry, err := load(ctx, deviceID)
+		if err != nil {
+			return model.CircuitBreakerEntry{}, err
+		}
+
+		version := entry.Version
+		if err := update(&entry); errors.Is(err, errUnchanged) {
+			return entry, nil
+		} else if err != nil {
+			return model.CircuitBreakerEntry{}, err
+		}
+
+		err = s.storage.CompareAndSwap(ctx, deviceID, version, entry)
+		if errors.Is(err, generic_storage.ErrConflict) {
+			s.logger.Debug("Entry changed concurrently, retrying", "deviceID", deviceID, "attempt", attempt)
+			continue
+		}
+		if err != nil {
+			return model.CircuitBreakerEntry{}, fmt.Errorf("failed to store entry: %w", err)
+		}
+
+		entry.Version = version + 1
+		return entry, nil
+	}
+
+	return model.CircuitBreakerEntry{}, fmt.Errorf("failed to store entry after %d attempts: %w", maxUpdateAttempts, generic_storage.ErrConflict)
+}
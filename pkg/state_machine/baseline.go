@@ -0,0 +1,79 @@
+package state_machine
+
+import (
+	"math"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// DefaultBaselineAlpha is used when the weight of the newest call in the baseline is not configured.
+const DefaultBaselineAlpha = 0.01
+
+// DefaultBaselineWarmupCalls is used when the number of calls required to warm the baseline up is not configured.
+const DefaultBaselineWarmupCalls = 100
+
+// The baseline is sampled on each call, so it is independent of the window type.
+// The variance is the one of a single call outcome, the deviation of the window error rate is derived from it
+// by the number of calls in the window.
+
+// ErrorRateThreshold returns the error rate percentage the breaker trips at, false if it never trips on errors.
+// In the adaptive mode the lowest of the multiple and the deviation thresholds is used, once the baseline is warmed up.
+func ErrorRateThreshold(entry *model.CircuitBreakerEntry, stats WindowStats) (float64, bool) {
+	if !isAdaptive(entry) || entry.Baseline.CallsCnt < baselineWarmupCalls(entry) ||
+		(entry.BaselineMultiplier <= 0 && entry.BaselineDeviations <= 0) {
+		return float64(entry.ErrorsThreshold), entry.ErrorsThreshold > 0
+	}
+
+	baseline := entry.Baseline
+	threshold := math.Inf(1)
+	if entry.BaselineMultiplier > 0 {
+		threshold = baseline.ErrorRate * entry.BaselineMultiplier
+	}
+	if entry.BaselineDeviations > 0 && stats.CallsCnt > 0 {
+		deviation := math.Sqrt(baseline.Variance / float64(stats.CallsCnt))
+		threshold = min(threshold, baseline.ErrorRate+entry.BaselineDeviations*deviation)
+	}
+	if math.IsInf(threshold, 1) {
+		return 0, false
+	}
+
+	return max(threshold, entry.BaselineMinThreshold), true
+}
+
+// updateBaseline collects the outcome of the call into the baseline.
+func updateBaseline(entry *model.CircuitBreakerEntry, call Call) {
+	if !isAdaptive(entry) {
+		return
+	}
+
+	outcome := 0.0
+	if call.Failed {
+		outcome = 100
+	}
+
+	// the plain average is used until enough calls are collected, so the first calls do not dominate
+	baseline := &entry.Baseline
+	alpha := max(baselineAlpha(entry), 1/float64(baseline.CallsCnt+1))
+	diff := outcome - baseline.ErrorRate
+	baseline.ErrorRate += alpha * diff
+	baseline.Variance = (1 - alpha) * (baseline.Variance + alpha*diff*diff)
+	baseline.CallsCnt++
+}
+
+func isAdaptive(entry *model.CircuitBreakerEntry) bool {
+	return entry.ThresholdMode == model.ThresholdModeAdaptive
+}
+
+func baselineAlpha(entry *model.CircuitBreakerEntry) float64 {
+	if entry.BaselineAlpha <= 0 {
+		return DefaultBaselineAlpha
+	}
+	return entry.BaselineAlpha
+}
+
+func baselineWarmupCalls(entry *model.CircuitBreakerEntry) int {
+	if entry.BaselineWarmupCalls <= 0 {
+		return DefaultBaselineWarmupCalls
+	}
+	return entry.BaselineWarmupCalls
+}
@@ -0,0 +1,66 @@
+package state_machine
+
+import (
+	"strings"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// DefaultFailureCategory is assigned to the failures which match no classification rule.
+const DefaultFailureCategory = "uncategorized"
+
+// Classify returns the category of the failed call and the weight it is counted with, zero if it is ignored.
+// The failures matching no rule are counted as usual.
+func Classify(entry *model.CircuitBreakerEntry, call Call) (string, float64) {
+	classification := entry.FailureClassification
+	if classification == nil {
+		return DefaultFailureCategory, 1
+	}
+
+	for _, rule := range classification.Rules {
+		if !matchesRule(rule, call) {
+			continue
+		}
+
+		for _, category := range classification.Categories {
+			if category.Name == rule.Category {
+				return category.Name, categoryWeight(category)
+			}
+		}
+	}
+
+	return DefaultFailureCategory, 1
+}
+
+// callWeight returns the weight of the call in the rolling window, a successful call is always counted once.
+func callWeight(entry *model.CircuitBreakerEntry, call Call) float64 {
+	if !call.Failed {
+		return 1
+	}
+	_, weight := Classify(entry, call)
+	return weight
+}
+
+func countFailureCategory(entry *model.CircuitBreakerEntry, category string) {
+	if entry.FailureCategoriesCnt == nil {
+		entry.FailureCategoriesCnt = make(map[string]int)
+	}
+	entry.FailureCategoriesCnt[category]++
+}
+
+func matchesRule(rule model.FailureRule, call Call) bool {
+	if rule.ErrorCode != "" && rule.ErrorCode != call.ErrorCode {
+		return false
+	}
+	return rule.Reason == "" || strings.Contains(strings.ToLower(call.FailureReason), strings.ToLower(rule.Reason))
+}
+
+func categoryWeight(category model.FailureCategory) float64 {
+	switch category.Action {
+	case model.FailureActionIgnore:
+		return 0
+	case model.FailureActionWeight:
+		return category.Weight
+	}
+	return 1
+}
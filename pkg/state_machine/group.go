@@ -0,0 +1,23 @@
+package state_machine
+
+import (
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// The parent breaker does not change the state of its members, the members keep
+// collecting their own calls and only report StateOpen while the parent is open.
+
+// ParentOpen returns true if the parent breaker makes the members of its group unavailable.
+func ParentOpen(parent *model.CircuitBreakerEntry, now time.Time) bool {
+	return parent != nil && EffectiveState(parent, now) == model.StateOpen
+}
+
+// GroupState returns the effective state of the group member, parent is nil if the device belongs to no group.
+func GroupState(entry *model.CircuitBreakerEntry, parent *model.CircuitBreakerEntry, now time.Time) model.State {
+	if ParentOpen(parent, now) {
+		return model.StateOpen
+	}
+	return EffectiveState(entry, now)
+}
@@ -0,0 +1,96 @@
+package state_machine
+
+import (
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// The override does not change entry.State, the automatic state is kept underneath
+// and is in effect again once the override expires or is cleared.
+// The expired override is ignored right away, but stays in the entry until ExpireOverride is called,
+// so the transition it caused can be recorded.
+
+// SetOverride sets the administrative override, replacing the previous one.
+// Returns the transition and true if the effective state of the circuit breaker was changed.
+func SetOverride(entry *model.CircuitBreakerEntry, mode string, reason string, expiresAt time.Time, now time.Time) (model.Transition, bool) {
+	prevState := EffectiveState(entry, now)
+	entry.Override = &model.Override{
+		Mode:      mode,
+		Reason:    reason,
+		SetAt:     now,
+		ExpiresAt: expiresAt,
+	}
+	return overrideTransition(entry, prevState, now)
+}
+
+// ClearOverride removes the administrative override, if any.
+// Returns the transition and true if the effective state of the circuit breaker was changed.
+func ClearOverride(entry *model.CircuitBreakerEntry, now time.Time) (model.Transition, bool) {
+	prevState := EffectiveState(entry, now)
+	entry.Override = nil
+	return overrideTransition(entry, prevState, now)
+}
+
+// ExpireOverride removes the override if it has expired.
+// Returns the transition and true if the effective state of the circuit breaker was changed.
+func ExpireOverride(entry *model.CircuitBreakerEntry, now time.Time) (model.Transition, bool) {
+	if entry.Override == nil || ActiveOverride(entry, now) != nil {
+		return model.Transition{}, false
+	}
+
+	prevState := overrideState(entry.Override)
+	entry.Override = nil
+	return overrideTransition(entry, prevState, now)
+}
+
+// ActiveOverride returns the override in effect at the given moment, nil if there is none or it has expired.
+func ActiveOverride(entry *model.CircuitBreakerEntry, now time.Time) *model.Override {
+	if entry.Override == nil {
+		return nil
+	}
+
+	if !entry.Override.ExpiresAt.IsZero() && !now.Before(entry.Override.ExpiresAt) {
+		return nil
+	}
+
+	return entry.Override
+}
+
+// EffectiveState returns the state of the circuit breaker with the override taken into account.
+func EffectiveState(entry *model.CircuitBreakerEntry, now time.Time) model.State {
+	override := ActiveOverride(entry, now)
+	if override == nil {
+		return entry.State
+	}
+	return overrideState(override)
+}
+
+func overrideState(override *model.Override) model.State {
+	if override.Mode == model.OverrideDisabled {
+		return model.StateClosed
+	}
+	return model.StateOpen
+}
+
+func overrideTransition(entry *model.CircuitBreakerEntry, prevState model.State, now time.Time) (model.Transition, bool) {
+	state := EffectiveState(entry, now)
+	if state == prevState {
+		return model.Transition{}, false
+	}
+	return newTransition(entry, prevState, state, model.TriggerOverride, now), true
+}
+
+// reportOverridden registers the outcome of a call made while the override is in effect,
+// the outcome is collected in the rolling window but never changes the state.
+func reportOverridden(entry *model.CircuitBreakerEntry, override *model.Override, call Call, now time.Time) {
+	if call.PermitID != "" {
+		releasePermit(entry, call.PermitID)
+	}
+
+	if override.Mode == model.OverrideIsolated {
+		return
+	}
+
+	countCall(entry, call, now)
+}
@@ -0,0 +1,144 @@
+package state_machine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// DefaultHalfOpenMaxPermits is used when the number of half-open permits is not configured.
+const DefaultHalfOpenMaxPermits = 1
+
+// DefaultPermitTimeoutMs is used when the permit timeout is not configured.
+const DefaultPermitTimeoutMs = 30000
+
+// Decision represents the answer to the caller asking for a permission to call the device.
+type Decision struct {
+	Allowed  bool
+	PermitID string // Set if the outcome of the call should be reported with it
+}
+
+// Acquire decides whether the device may be called.
+// A closed breaker allows every call, except the ones not admitted while it ramps up after recovery,
+// and an open one denies every call.
+// A half-open breaker hands out at most HalfOpenMaxPermits permits for the trial calls,
+// a permit which outcome was not reported within PermitTimeoutMs is released.
+// If MaxConcurrentCalls is set, the closed breaker hands out permits as well and both closed and half-open
+// ones reject the call once MaxConcurrentCalls permits are in flight, the rejection is counted in RejectedCallsCnt.
+// The administrative override wins over the state.
+func Acquire(entry *model.CircuitBreakerEntry, now time.Time) Decision {
+	if override := ActiveOverride(entry, now); override != nil {
+		return Decision{Allowed: override.Mode == model.OverrideDisabled}
+	}
+
+	switch entry.State {
+	case model.StateClosed:
+		if !admitRampUp(entry, now) {
+			return Decision{Allowed: false}
+		}
+		if entry.MaxConcurrentCalls <= 0 {
+			return Decision{Allowed: true}
+		}
+		dropExpiredPermits(entry, now)
+	case model.StateOpen:
+		return Decision{Allowed: false}
+	default:
+		dropExpiredPermits(entry, now)
+		if len(entry.Permits)+entry.HalfOpenSuccessesCnt >= halfOpenMaxPermits(entry) {
+			return Decision{Allowed: false}
+		}
+	}
+
+	if entry.MaxConcurrentCalls > 0 && len(entry.Permits) >= entry.MaxConcurrentCalls {
+		entry.RejectedCallsCnt++
+		return Decision{Allowed: false}
+	}
+
+	permit := model.Permit{ID: newPermitID(), IssuedAt: now}
+	entry.Permits = append(entry.Permits, permit)
+	return Decision{Allowed: true, PermitID: permit.ID}
+}
+
+// Release gives the permit back without reporting the outcome of the call, e.g. when the call was cancelled.
+// Returns false if the permit is unknown, most likely it is expired or was issued before the last state change.
+func Release(entry *model.CircuitBreakerEntry, permitID string) bool {
+	return releasePermit(entry, permitID)
+}
+
+// InFlightCalls returns the number of permits which were neither reported nor released nor expired by now.
+func InFlightCalls(entry *model.CircuitBreakerEntry, now time.Time) int {
+	timeout := time.Duration(permitTimeoutMs(entry)) * time.Millisecond
+	cnt := 0
+	for _, permit := range entry.Permits {
+		if now.Before(permit.IssuedAt.Add(timeout)) {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// Outcomes reported without a permit are treated as trial calls as well,
+// so the callers which do not acquire permits keep working. An unknown permit is most likely
+// expired or issued before the last state change, its outcome is ignored.
+func reportTrial(entry *model.CircuitBreakerEntry, call Call, now time.Time) (model.Transition, bool) {
+	if call.PermitID != "" && !releasePermit(entry, call.PermitID) {
+		return model.Transition{}, false
+	}
+
+	if call.Failed || isSlow(entry, call) {
+		// the trial call failed, the device is still unhealthy
+		return setState(entry, model.StateOpen, model.TriggerAutomatic, now), true
+	}
+
+	entry.HalfOpenSuccessesCnt++
+	if entry.HalfOpenSuccessesCnt >= halfOpenMaxPermits(entry) {
+		// all trial calls succeeded, the device is healthy again
+		transition := setState(entry, model.StateClosed, model.TriggerAutomatic, now)
+		if entry.RampUpDurationMs > 0 {
+			entry.RampUpStartedAt = now
+		}
+		return transition, true
+	}
+
+	return model.Transition{}, false
+}
+
+func releasePermit(entry *model.CircuitBreakerEntry, permitID string) bool {
+	i := slices.IndexFunc(entry.Permits, func(p model.Permit) bool { return p.ID == permitID })
+	if i < 0 {
+		return false
+	}
+	entry.Permits = slices.Delete(entry.Permits, i, i+1)
+	return true
+}
+
+func dropExpiredPermits(entry *model.CircuitBreakerEntry, now time.Time) {
+	timeout := time.Duration(permitTimeoutMs(entry)) * time.Millisecond
+	entry.Permits = slices.DeleteFunc(entry.Permits, func(p model.Permit) bool {
+		return !now.Before(p.IssuedAt.Add(timeout))
+	})
+}
+
+func halfOpenMaxPermits(entry *model.CircuitBreakerEntry) int {
+	if entry.HalfOpenMaxPermits <= 0 {
+		return DefaultHalfOpenMaxPermits
+	}
+	return entry.HalfOpenMaxPermits
+}
+
+func permitTimeoutMs(entry *model.CircuitBreakerEntry) int {
+	if entry.PermitTimeoutMs <= 0 {
+		return DefaultPermitTimeoutMs
+	}
+	return entry.PermitTimeoutMs
+}
+
+func newPermitID() string {
+	var id [16]byte
+	// crypto/rand.Read never returns an error on supported platforms
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
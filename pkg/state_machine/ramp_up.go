@@ -0,0 +1,37 @@
+package state_machine
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// RampUpPercentage returns the percentage of calls admitted by the closed breaker at the given moment,
+// it grows from the recovery to 100% over RampUpDurationMs.
+func RampUpPercentage(entry *model.CircuitBreakerEntry, now time.Time) float64 {
+	if entry.State != model.StateClosed || entry.RampUpStartedAt.IsZero() || entry.RampUpDurationMs <= 0 {
+		return 100
+	}
+
+	duration := time.Duration(entry.RampUpDurationMs) * time.Millisecond
+	progress := float64(now.Sub(entry.RampUpStartedAt)) / float64(duration)
+	if progress >= 1 {
+		return 100
+	}
+	progress = max(progress, 0)
+
+	if entry.RampUpType == model.RampUpTypeExponential {
+		// 1% at the start, 100% at the end
+		return math.Pow(100, progress)
+	}
+	return 100 * progress
+}
+
+// The calls are admitted at random, so the admitted percentage holds for any number of callers
+// without keeping counters in the entry.
+func admitRampUp(entry *model.CircuitBreakerEntry, now time.Time) bool {
+	percentage := RampUpPercentage(entry, now)
+	return percentage >= 100 || rand.Float64()*100 < percentage
+}
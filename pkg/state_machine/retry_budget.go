@@ -0,0 +1,29 @@
+package state_machine
+
+import (
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// RetriesLimit returns the number of retries allowed in the rolling window,
+// RetryBudgetPercentage of the first attempts but at least RetryBudgetMinRetries.
+// Returns false if there is no retry budget.
+func RetriesLimit(entry *model.CircuitBreakerEntry, stats WindowStats) (int, bool) {
+	if entry.RetryBudgetPercentage <= 0 {
+		return 0, false
+	}
+
+	firstAttemptsCnt := stats.CallsCnt - stats.RetriesCnt
+	return max(firstAttemptsCnt*entry.RetryBudgetPercentage/100, entry.RetryBudgetMinRetries), true
+}
+
+// AllowRetry decides whether the caller may retry a failed call, i.e. whether one more retry fits into the budget.
+// NOTE (maksym): nothing is reserved, the retry is counted once it is reported, so the concurrent callers
+// may exceed the budget slightly. The state of the breaker is not taken into account, the retry should
+// be acquired as any other call.
+func AllowRetry(entry *model.CircuitBreakerEntry, now time.Time) bool {
+	stats := Stats(entry, now)
+	limit, ok := RetriesLimit(entry, stats)
+	return !ok || stats.RetriesCnt < limit
+}
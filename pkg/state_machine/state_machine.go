@@ -0,0 +1,185 @@
+package state_machine
+
+import (
+	"math"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// The state machine works on the entry only and never touches storage,
+// the caller is responsible for loading the entry and storing it back.
+
+// Call represents the reported outcome of a call to the device.
+type Call struct {
+	Failed        bool
+	FailureReason string
+	ErrorCode     string        // Error code of the failure, see Classify
+	PermitID      string        // Permit the call was made with, see Acquire
+	Duration      time.Duration // Duration of the call, zero if not measured
+	Retry         bool          // Whether the call was a retry, see AllowRetry
+}
+
+// Report registers the outcome of a call and updates the state of the circuit breaker.
+// A closed breaker moves to StateOpen once the percentage of failed calls in the rolling window
+// reaches ErrorRateThreshold or the percentage of slow calls reaches SlowCallRateThreshold,
+// provided that at least MinimumCalls calls were collected in the window.
+// A half-open breaker moves to StateOpen on a failed or slow trial call and to StateClosed
+// once HalfOpenMaxPermits trial calls succeeded.
+// The failures classified as ignored affect neither the rolling window nor the trial calls.
+// The state is never changed while an administrative override is in effect.
+// Returns the transition and true if the state of the circuit breaker was changed.
+func Report(entry *model.CircuitBreakerEntry, call Call, now time.Time) (model.Transition, bool) {
+	if call.Failed {
+		entry.LastFailureReason = call.FailureReason
+
+		category, weight := Classify(entry, call)
+		countFailureCategory(entry, category)
+		if weight == 0 {
+			// the failure tells nothing about the health of the device, e.g. a client error
+			if call.PermitID != "" {
+				releasePermit(entry, call.PermitID)
+			}
+			return model.Transition{}, false
+		}
+	}
+
+	if override := ActiveOverride(entry, now); override != nil {
+		reportOverridden(entry, override, call, now)
+		return model.Transition{}, false
+	}
+
+	switch entry.State {
+	case model.StateOpen:
+		// the breaker is already tripped, nothing to count
+		return model.Transition{}, false
+	case model.StateHalfOpen:
+		return reportTrial(entry, call, now)
+	}
+
+	if call.PermitID != "" {
+		// the call is counted even if the permit has expired, it tells about the health of the device anyway
+		releasePermit(entry, call.PermitID)
+	}
+	countCall(entry, call, now)
+
+	if shouldTrip(entry, Stats(entry, now)) {
+		return setState(entry, model.StateOpen, model.TriggerAutomatic, now), true
+	}
+
+	return model.Transition{}, false
+}
+
+// ReportFailure registers a failed call, see Report.
+func ReportFailure(entry *model.CircuitBreakerEntry, reason string, now time.Time) (model.Transition, bool) {
+	return Report(entry, Call{Failed: true, FailureReason: reason}, now)
+}
+
+// ReportSuccess registers a successful call, see Report.
+func ReportSuccess(entry *model.CircuitBreakerEntry, now time.Time) (model.Transition, bool) {
+	return Report(entry, Call{}, now)
+}
+
+// HalfOpen moves an open circuit breaker to StateHalfOpen once the reset timeout has passed since LastChanged.
+// Returns the transition and true if the state of the circuit breaker was changed.
+func HalfOpen(entry *model.CircuitBreakerEntry, now time.Time) (model.Transition, bool) {
+	if entry.State != model.StateOpen || entry.ResetTimeoutMs <= 0 {
+		return model.Transition{}, false
+	}
+
+	if now.Before(ResetDeadline(entry)) || ActiveOverride(entry, now) != nil {
+		return model.Transition{}, false
+	}
+
+	return setState(entry, model.StateHalfOpen, model.TriggerAutomatic, now), true
+}
+
+// ResetDeadline returns the moment when an open circuit breaker should be moved to StateHalfOpen.
+func ResetDeadline(entry *model.CircuitBreakerEntry) time.Time {
+	return entry.LastChanged.Add(time.Duration(ResetTimeoutMs(entry)) * time.Millisecond)
+}
+
+// NextTransition returns the moment when ExpireOverride and HalfOpen should be attempted for the circuit breaker,
+// false if the breaker will not change its state by itself.
+func NextTransition(entry *model.CircuitBreakerEntry, now time.Time) (time.Time, bool) {
+	if entry.Override != nil {
+		// the reset deadline is tracked again once the override has expired
+		return entry.Override.ExpiresAt, !entry.Override.ExpiresAt.IsZero()
+	}
+
+	if entry.State != model.StateOpen || entry.ResetTimeoutMs <= 0 {
+		return time.Time{}, false
+	}
+
+	return ResetDeadline(entry), true
+}
+
+// ResetTimeoutMs returns the reset timeout of the breaker grown by BackoffMultiplier
+// on each consecutive trip and limited by MaxResetTimeoutMs.
+func ResetTimeoutMs(entry *model.CircuitBreakerEntry) int {
+	timeout := float64(entry.ResetTimeoutMs)
+	if entry.BackoffMultiplier > 1 && entry.ConsecutiveTripsCnt > 1 {
+		timeout *= math.Pow(entry.BackoffMultiplier, float64(entry.ConsecutiveTripsCnt-1))
+	}
+
+	if entry.MaxResetTimeoutMs > 0 && timeout > float64(entry.MaxResetTimeoutMs) {
+		return entry.MaxResetTimeoutMs
+	}
+	return int(timeout)
+}
+
+// Reset moves the circuit breaker to StateClosed and clears the rolling window.
+// Returns the transition and true if the state of the circuit breaker was changed.
+func Reset(entry *model.CircuitBreakerEntry, now time.Time) (model.Transition, bool) {
+	prevState := entry.State
+	transition := setState(entry, model.StateClosed, model.TriggerReset, now)
+	return transition, prevState != model.StateClosed
+}
+
+func shouldTrip(entry *model.CircuitBreakerEntry, stats WindowStats) bool {
+	if stats.CallsCnt < entry.MinimumCalls {
+		// not enough calls to judge the health of the device
+		return false
+	}
+
+	if threshold, ok := ErrorRateThreshold(entry, stats); ok && stats.FailuresCnt > 0 && stats.ErrorRate() >= threshold {
+		return true
+	}
+
+	return entry.SlowCallRateThreshold > 0 && stats.SlowCallRate() >= float64(entry.SlowCallRateThreshold)
+}
+
+// setState changes the state and returns the transition, the rolling window is snapshotted before it is cleared.
+func setState(entry *model.CircuitBreakerEntry, state model.State, trigger string, now time.Time) model.Transition {
+	transition := newTransition(entry, entry.State, state, trigger, now)
+
+	switch state {
+	case model.StateOpen:
+		entry.ConsecutiveTripsCnt++
+	case model.StateClosed:
+		entry.ConsecutiveTripsCnt = 0
+		entry.FailureCategoriesCnt = nil
+	}
+
+	entry.State = state
+	entry.LastChanged = now
+	entry.Window = nil
+	entry.Permits = nil
+	entry.HalfOpenSuccessesCnt = 0
+	entry.RampUpStartedAt = time.Time{}
+
+	return transition
+}
+
+func newTransition(entry *model.CircuitBreakerEntry, from model.State, to model.State, trigger string, now time.Time) model.Transition {
+	stats := Stats(entry, now)
+	return model.Transition{
+		DeviceID:     entry.DeviceID,
+		From:         from,
+		To:           to,
+		Timestamp:    now,
+		Trigger:      trigger,
+		ErrorRate:    stats.ErrorRate(),
+		SlowCallRate: stats.SlowCallRate(),
+	}
+}
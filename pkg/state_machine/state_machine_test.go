@@ -0,0 +1,770 @@
+package state_machine_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/state_machine"
+)
+
+func TestReportFailureTripsOnThreshold(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold:         50,
+			ErrorsCntResetTimeoutMs: 10000,
+		},
+	}
+	state_machine.ReportSuccess(&entry, now)
+	state_machine.ReportSuccess(&entry, now)
+	state_machine.ReportFailure(&entry, "timeout", now)
+
+	// Act
+	_, changed := state_machine.ReportFailure(&entry, "timeout", now.Add(time.Second))
+
+	// Assert
+	if !changed || entry.State != model.StateOpen {
+		t.Fatalf("Expected state %v, but got: %v", model.StateOpen, entry.State)
+	}
+	if entry.LastFailureReason != "timeout" {
+		t.Fatalf("Expected failure reason to be stored, but got: '%s'", entry.LastFailureReason)
+	}
+}
+
+func TestReportFailureBelowThreshold(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold:         50,
+			ErrorsCntResetTimeoutMs: 10000,
+		},
+	}
+	for i := 0; i < 3; i++ {
+		state_machine.ReportSuccess(&entry, now)
+	}
+
+	// Act
+	_, changed := state_machine.ReportFailure(&entry, "", now)
+
+	// Assert
+	if changed || entry.State != model.StateClosed {
+		t.Fatalf("Expected state %v, but got: %v", model.StateClosed, entry.State)
+	}
+	if rate := state_machine.Stats(&entry, now).ErrorRate(); rate != 25 {
+		t.Fatalf("Expected error rate 25, but got: %v", rate)
+	}
+}
+
+func TestReportFailureRollingWindowSlides(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold:         100,
+			ErrorsCntResetTimeoutMs: 1000,
+		},
+	}
+	state_machine.ReportSuccess(&entry, now)
+
+	// Act
+	state_machine.ReportFailure(&entry, "", now.Add(2*time.Second))
+
+	// Assert
+	if entry.State != model.StateOpen {
+		t.Fatalf("Expected state %v, but got: %v", model.StateOpen, entry.State)
+	}
+}
+
+func TestReportFailureInHalfOpenReopens(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateHalfOpen,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold: 10,
+		},
+	}
+
+	// Act
+	_, changed := state_machine.ReportFailure(&entry, "", now)
+
+	// Assert
+	if !changed || entry.State != model.StateOpen {
+		t.Fatalf("Expected state %v, but got: %v", model.StateOpen, entry.State)
+	}
+	if !entry.LastChanged.Equal(now) {
+		t.Fatalf("Expected LastChanged to be updated, but got: %v", entry.LastChanged)
+	}
+}
+
+func TestReportSuccessInHalfOpenCloses(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateHalfOpen,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold: 10,
+		},
+	}
+
+	// Act
+	_, changed := state_machine.ReportSuccess(&entry, now)
+
+	// Assert
+	if !changed || entry.State != model.StateClosed {
+		t.Fatalf("Expected state %v, but got: %v", model.StateClosed, entry.State)
+	}
+}
+
+func TestStatsRollingWindow(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			WindowLengthMs:   10000,
+			WindowBucketsCnt: 10,
+		},
+	}
+	state_machine.ReportFailure(&entry, "", now)
+	state_machine.ReportSuccess(&entry, now.Add(5*time.Second))
+	state_machine.ReportSuccess(&entry, now.Add(9*time.Second))
+
+	// Act
+	before := state_machine.Stats(&entry, now.Add(9*time.Second))
+	after := state_machine.Stats(&entry, now.Add(11*time.Second))
+
+	// Assert
+	if before.CallsCnt != 3 || before.FailuresCnt != 1 {
+		t.Fatalf("Expected 3 calls and 1 failure, but got: %+v", before)
+	}
+	if after.CallsCnt != 2 || after.FailuresCnt != 0 {
+		t.Fatalf("Expected the oldest bucket to slide out of the window, but got: %+v", after)
+	}
+}
+
+func TestStatsCountWindow(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			WindowType: model.WindowTypeCount,
+			WindowSize: 4,
+		},
+	}
+	state_machine.ReportFailure(&entry, "", now)
+	for i := 0; i < 4; i++ {
+		state_machine.ReportSuccess(&entry, now.Add(time.Hour))
+	}
+
+	// Act
+	stats := state_machine.Stats(&entry, now.Add(24*time.Hour))
+
+	// Assert
+	if stats.CallsCnt != 4 || stats.FailuresCnt != 0 {
+		t.Fatalf("Expected the last 4 calls without failures, but got: %+v", stats)
+	}
+}
+
+func TestReportFailureBelowMinimumCalls(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold: 50,
+			WindowLengthMs:  10000,
+			MinimumCalls:    3,
+		},
+	}
+	state_machine.ReportFailure(&entry, "", now)
+
+	// Act
+	_, changed := state_machine.ReportFailure(&entry, "", now)
+
+	// Assert
+	if changed || entry.State != model.StateClosed {
+		t.Fatalf("Expected state %v below minimum calls, but got: %v", model.StateClosed, entry.State)
+	}
+	if _, changed := state_machine.ReportFailure(&entry, "", now); !changed {
+		t.Fatalf("Expected the breaker to trip once minimum calls are reached, but got: %v", entry.State)
+	}
+}
+
+func TestAcquireHalfOpenLimitsPermits(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateHalfOpen,
+		BreakerConfig: model.BreakerConfig{
+			HalfOpenMaxPermits: 2,
+			PermitTimeoutMs:    1000,
+		},
+	}
+
+	// Act
+	first := state_machine.Acquire(&entry, now)
+	second := state_machine.Acquire(&entry, now)
+	denied := state_machine.Acquire(&entry, now)
+	afterTimeout := state_machine.Acquire(&entry, now.Add(2*time.Second))
+
+	// Assert
+	if !first.Allowed || !second.Allowed || first.PermitID == "" || first.PermitID == second.PermitID {
+		t.Fatalf("Expected two distinct permits, but got: %+v, %+v", first, second)
+	}
+	if denied.Allowed {
+		t.Fatalf("Expected the third call to be denied, but got: %+v", denied)
+	}
+	if !afterTimeout.Allowed {
+		t.Fatalf("Expected expired permits to be released, but got: %+v", afterTimeout)
+	}
+}
+
+func TestReportTrialClosesAfterAllPermitsSucceeded(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateHalfOpen,
+		BreakerConfig: model.BreakerConfig{
+			HalfOpenMaxPermits: 2,
+		},
+	}
+	first := state_machine.Acquire(&entry, now)
+	second := state_machine.Acquire(&entry, now)
+
+	// Act
+	_, ignored := state_machine.Report(&entry, state_machine.Call{Failed: true, PermitID: "unknown"}, now)
+	state_machine.Report(&entry, state_machine.Call{PermitID: first.PermitID}, now)
+	_, closed := state_machine.Report(&entry, state_machine.Call{PermitID: second.PermitID}, now)
+
+	// Assert
+	if ignored {
+		t.Fatalf("Expected the outcome of an unknown permit to be ignored")
+	}
+	if !closed || entry.State != model.StateClosed {
+		t.Fatalf("Expected state %v, but got: %v", model.StateClosed, entry.State)
+	}
+}
+
+func TestResetTimeoutBackoff(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold:   50,
+			ResetTimeoutMs:    1000,
+			BackoffMultiplier: 2,
+			MaxResetTimeoutMs: 3000,
+		},
+	}
+	var timeouts []int
+
+	// Act
+	state_machine.ReportFailure(&entry, "", now)
+	for i := 0; i < 3; i++ {
+		timeouts = append(timeouts, state_machine.ResetTimeoutMs(&entry))
+		now = state_machine.ResetDeadline(&entry)
+		state_machine.HalfOpen(&entry, now)
+		state_machine.ReportFailure(&entry, "", now)
+	}
+	state_machine.HalfOpen(&entry, state_machine.ResetDeadline(&entry))
+	state_machine.ReportSuccess(&entry, now)
+
+	// Assert
+	if timeouts[0] != 1000 || timeouts[1] != 2000 || timeouts[2] != 3000 {
+		t.Fatalf("Expected reset timeouts [1000 2000 3000], but got: %v", timeouts)
+	}
+	if entry.State != model.StateClosed || state_machine.ResetTimeoutMs(&entry) != 1000 {
+		t.Fatalf("Expected the backoff to be reset on close, but got: %d", state_machine.ResetTimeoutMs(&entry))
+	}
+}
+
+func TestReportSlowCallsTrip(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold:       50,
+			WindowLengthMs:        10000,
+			MinimumCalls:          4,
+			SlowCallDurationMs:    1000,
+			SlowCallRateThreshold: 75,
+		},
+	}
+	slow := state_machine.Call{Duration: 2 * time.Second}
+	state_machine.Report(&entry, state_machine.Call{Duration: 10 * time.Millisecond}, now)
+	state_machine.Report(&entry, slow, now)
+	state_machine.Report(&entry, slow, now)
+
+	// Act
+	_, changed := state_machine.Report(&entry, slow, now)
+
+	// Assert
+	if !changed || entry.State != model.StateOpen {
+		t.Fatalf("Expected state %v on slow calls, but got: %v", model.StateOpen, entry.State)
+	}
+}
+
+func TestOverrideWinsUntilExpired(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold: 50,
+			WindowLengthMs:  60000,
+		},
+	}
+	state_machine.SetOverride(&entry, model.OverrideDisabled, "maintenance", now.Add(time.Minute), now)
+
+	// Act
+	_, changed := state_machine.ReportFailure(&entry, "", now)
+	decision := state_machine.Acquire(&entry, now)
+	stateAfterExpiry := state_machine.EffectiveState(&entry, now.Add(2*time.Minute))
+
+	// Assert
+	if changed || !decision.Allowed || state_machine.EffectiveState(&entry, now) != model.StateClosed {
+		t.Fatalf("Expected the disabled breaker to allow calls, but got: %+v", decision)
+	}
+	if state_machine.ActiveOverride(&entry, now.Add(2*time.Minute)) != nil || stateAfterExpiry != model.StateClosed {
+		t.Fatalf("Expected the override to be ignored after expiry, but got: %+v", entry.Override)
+	}
+	if state_machine.Stats(&entry, now).FailuresCnt != 1 {
+		t.Fatalf("Expected the failure to be collected while disabled")
+	}
+}
+
+func TestOverrideForcedOpenDenies(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{DeviceID: 1, State: model.StateClosed}
+	state_machine.SetOverride(&entry, model.OverrideForcedOpen, "incident", time.Time{}, now)
+
+	// Act
+	decision := state_machine.Acquire(&entry, now.Add(24*time.Hour))
+
+	// Assert
+	if decision.Allowed || state_machine.EffectiveState(&entry, now) != model.StateOpen {
+		t.Fatalf("Expected the forced-open breaker to deny calls, but got: %+v", decision)
+	}
+	if entry.State != model.StateClosed {
+		t.Fatalf("Expected the automatic state to be kept, but got: %v", entry.State)
+	}
+}
+
+func TestTransitionSnapshotsErrorRate(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 3,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold: 50,
+			WindowLengthMs:  60000,
+		},
+	}
+	state_machine.ReportSuccess(&entry, now)
+
+	// Act
+	transition, changed := state_machine.ReportFailure(&entry, "", now)
+
+	// Assert
+	if !changed {
+		t.Fatalf("Expected the breaker to trip")
+	}
+	expected := model.Transition{
+		DeviceID:  3,
+		From:      model.StateClosed,
+		To:        model.StateOpen,
+		Timestamp: now,
+		Trigger:   model.TriggerAutomatic,
+		ErrorRate: 50,
+	}
+	if transition != expected {
+		t.Fatalf("Expected transition %+v, but got: %+v", expected, transition)
+	}
+}
+
+func TestExpireOverrideRecordsTransition(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{DeviceID: 1, State: model.StateClosed}
+	_, set := state_machine.SetOverride(&entry, model.OverrideForcedOpen, "incident", now.Add(time.Minute), now)
+
+	// Act
+	_, early := state_machine.ExpireOverride(&entry, now)
+	transition, expired := state_machine.ExpireOverride(&entry, now.Add(time.Minute))
+
+	// Assert
+	if !set || early {
+		t.Fatalf("Expected the override to change the state until expired, but got: %v, %v", set, early)
+	}
+	if !expired || transition.From != model.StateOpen || transition.To != model.StateClosed || transition.Trigger != model.TriggerOverride {
+		t.Fatalf("Expected the override transition on expiry, but got: %+v", transition)
+	}
+	if entry.Override != nil {
+		t.Fatalf("Expected the expired override to be cleared, but got: %+v", entry.Override)
+	}
+}
+
+func TestClassifiedFailuresIgnoredAndWeighted(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold: 50,
+			WindowLengthMs:  60000,
+			FailureClassification: &model.FailureClassification{
+				Categories: []model.FailureCategory{
+					{Name: "client-error", Action: model.FailureActionIgnore},
+					{Name: "timeout", Action: model.FailureActionWeight, Weight: 2},
+				},
+				Rules: []model.FailureRule{
+					{Category: "client-error", ErrorCode: "400"},
+					{Category: "timeout", Reason: "timeout"},
+				},
+			},
+		},
+	}
+	state_machine.ReportSuccess(&entry, now)
+	state_machine.ReportSuccess(&entry, now)
+
+	// Act
+	_, ignoredChanged := state_machine.Report(&entry, state_machine.Call{Failed: true, ErrorCode: "400"}, now)
+	_, weightedChanged := state_machine.Report(&entry, state_machine.Call{Failed: true, FailureReason: "Read Timeout"}, now)
+
+	// Assert
+	if ignoredChanged || !weightedChanged {
+		t.Fatalf("Expected only the weighted failure to trip the breaker, but got: %v, %v", ignoredChanged, weightedChanged)
+	}
+	expected := map[string]int{"client-error": 1, "timeout": 1}
+	if len(entry.FailureCategoriesCnt) != 2 ||
+		entry.FailureCategoriesCnt["client-error"] != expected["client-error"] ||
+		entry.FailureCategoriesCnt["timeout"] != expected["timeout"] {
+		t.Fatalf("Expected categories %v, but got: %v", expected, entry.FailureCategoriesCnt)
+	}
+}
+
+func TestFailureCategoriesKeptUntilClosed(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID:      1,
+		State:         model.StateClosed,
+		BreakerConfig: model.BreakerConfig{ErrorsThreshold: 50, WindowLengthMs: 60000, ResetTimeoutMs: 1000},
+	}
+	state_machine.ReportFailure(&entry, "timeout", now)
+
+	// Act
+	_, halfOpened := state_machine.HalfOpen(&entry, now.Add(time.Second))
+	halfOpenCnt := len(entry.FailureCategoriesCnt)
+	state_machine.Reset(&entry, now.Add(time.Second))
+
+	// Assert
+	if !halfOpened || halfOpenCnt != 1 {
+		t.Fatalf("Expected the categories to be kept while the breaker is not closed, but got: %v, %d", halfOpened, halfOpenCnt)
+	}
+	if entry.FailureCategoriesCnt != nil {
+		t.Fatalf("Expected the categories to be cleared once the breaker is closed, but got: %v", entry.FailureCategoriesCnt)
+	}
+}
+
+func TestClassifyUnmatchedFailure(t *testing.T) {
+	// Arrange
+	entry := model.CircuitBreakerEntry{
+		BreakerConfig: model.BreakerConfig{
+			FailureClassification: &model.FailureClassification{
+				Categories: []model.FailureCategory{{Name: "timeout", Action: model.FailureActionWeight, Weight: 2}},
+				Rules:      []model.FailureRule{{Category: "timeout", ErrorCode: "504", Reason: "timeout"}},
+			},
+		},
+	}
+
+	// Act
+	category, weight := state_machine.Classify(&entry, state_machine.Call{Failed: true, FailureReason: "timeout", ErrorCode: "500"})
+
+	// Assert
+	if category != state_machine.DefaultFailureCategory || weight != 1 {
+		t.Fatalf("Expected the failure to be counted as usual, but got: %s, %v", category, weight)
+	}
+}
+
+func TestGroupStateFollowsOpenParent(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	parentID := model.Key(1)
+	parent := model.CircuitBreakerEntry{DeviceID: parentID, State: model.StateOpen, Members: []model.Key{2}}
+	member := model.CircuitBreakerEntry{DeviceID: 2, State: model.StateClosed, ParentID: &parentID}
+
+	// Act
+	openState := state_machine.GroupState(&member, &parent, now)
+	parent.State = model.StateHalfOpen
+	halfOpenState := state_machine.GroupState(&member, &parent, now)
+
+	// Assert
+	if openState != model.StateOpen {
+		t.Fatalf("Expected the member to report %v while the parent is open, but got: %v", model.StateOpen, openState)
+	}
+	if halfOpenState != model.StateClosed {
+		t.Fatalf("Expected the member to report its own state, but got: %v", halfOpenState)
+	}
+}
+
+func TestAdaptiveThresholdFollowsBaseline(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	newEntry := func() model.CircuitBreakerEntry {
+		return model.CircuitBreakerEntry{
+			DeviceID: 1,
+			State:    model.StateClosed,
+			BreakerConfig: model.BreakerConfig{
+				ErrorsThreshold:     50,
+				WindowType:          model.WindowTypeCount,
+				WindowSize:          20,
+				ThresholdMode:       model.ThresholdModeAdaptive,
+				BaselineAlpha:       0.01,
+				BaselineMultiplier:  3,
+				BaselineWarmupCalls: 100,
+			},
+		}
+	}
+	noisy := newEntry()
+	quiet := newEntry()
+	for i := 0; i < 200; i++ {
+		// the noisy device fails every 10th call, the quiet one never fails
+		state_machine.Report(&noisy, state_machine.Call{Failed: i%10 == 9}, now)
+		state_machine.ReportSuccess(&quiet, now)
+	}
+	quiet.BaselineMinThreshold = 5
+
+	// Act
+	noisyThreshold, _ := state_machine.ErrorRateThreshold(&noisy, state_machine.Stats(&noisy, now))
+	_, noisyChanged := state_machine.ReportFailure(&noisy, "", now)
+	_, quietChanged := state_machine.ReportFailure(&quiet, "", now)
+
+	// Assert
+	if noisyThreshold < 25 || noisyThreshold > 35 {
+		t.Fatalf("Expected the threshold around 3 times the 10%% baseline, but got: %v", noisyThreshold)
+	}
+	if noisyChanged {
+		t.Fatalf("Expected a single failure to be within the noisy device baseline")
+	}
+	if !quietChanged || quiet.State != model.StateOpen {
+		t.Fatalf("Expected a single failure to trip the quiet device, but got: %v", quiet.State)
+	}
+}
+
+func TestAdaptiveThresholdFixedUntilWarmedUp(t *testing.T) {
+	// Arrange
+	entry := model.CircuitBreakerEntry{
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold:    50,
+			ThresholdMode:      model.ThresholdModeAdaptive,
+			BaselineMultiplier: 2,
+		},
+		Baseline: model.Baseline{ErrorRate: 1, CallsCnt: state_machine.DefaultBaselineWarmupCalls - 1},
+	}
+
+	// Act
+	threshold, ok := state_machine.ErrorRateThreshold(&entry, state_machine.WindowStats{CallsCnt: 10})
+
+	// Assert
+	if !ok || threshold != 50 {
+		t.Fatalf("Expected ErrorsThreshold until the baseline is warmed up, but got: %v", threshold)
+	}
+}
+
+func TestRampUpAfterRecovery(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateHalfOpen,
+		BreakerConfig: model.BreakerConfig{
+			HalfOpenMaxPermits: 1,
+			RampUpDurationMs:   10000,
+			RampUpType:         model.RampUpTypeLinear,
+		},
+	}
+
+	// Act
+	state_machine.ReportSuccess(&entry, now)
+	decision := state_machine.Acquire(&entry, now)
+	halfway := state_machine.RampUpPercentage(&entry, now.Add(5*time.Second))
+	finished := state_machine.RampUpPercentage(&entry, now.Add(10*time.Second))
+
+	// Assert
+	if entry.State != model.StateClosed || decision.Allowed {
+		t.Fatalf("Expected the recovered breaker to admit no calls at first, but got: %v, %+v", entry.State, decision)
+	}
+	if halfway != 50 || finished != 100 {
+		t.Fatalf("Expected 50%% and 100%% of calls admitted, but got: %v and %v", halfway, finished)
+	}
+}
+
+func TestRampUpExponential(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		State:           model.StateClosed,
+		RampUpStartedAt: now,
+		BreakerConfig: model.BreakerConfig{
+			RampUpDurationMs: 10000,
+			RampUpType:       model.RampUpTypeExponential,
+		},
+	}
+
+	// Act
+	start := state_machine.RampUpPercentage(&entry, now)
+	halfway := state_machine.RampUpPercentage(&entry, now.Add(5*time.Second))
+
+	// Assert
+	if start != 1 || math.Abs(halfway-10) > 1e-9 {
+		t.Fatalf("Expected 1%% and 10%% of calls admitted, but got: %v and %v", start, halfway)
+	}
+}
+
+func TestBulkheadLimitsConcurrentCalls(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			ErrorsThreshold:    50,
+			MaxConcurrentCalls: 2,
+			PermitTimeoutMs:    1000,
+		},
+	}
+
+	// Act
+	first := state_machine.Acquire(&entry, now)
+	second := state_machine.Acquire(&entry, now)
+	rejected := state_machine.Acquire(&entry, now)
+	state_machine.Report(&entry, state_machine.Call{PermitID: first.PermitID}, now)
+	afterReport := state_machine.Acquire(&entry, now)
+	released := state_machine.Release(&entry, second.PermitID)
+	afterRelease := state_machine.Acquire(&entry, now)
+
+	// Assert
+	if !first.Allowed || !second.Allowed || first.PermitID == "" || second.PermitID == "" {
+		t.Fatalf("Expected two leases, but got: %+v, %+v", first, second)
+	}
+	if rejected.Allowed || entry.RejectedCallsCnt != 1 {
+		t.Fatalf("Expected the third call to be rejected and counted, but got: %+v, %d rejected", rejected, entry.RejectedCallsCnt)
+	}
+	if !afterReport.Allowed || !released || !afterRelease.Allowed {
+		t.Fatalf("Expected the reported and released leases to be given back, but got: %+v, %v, %+v", afterReport, released, afterRelease)
+	}
+	if cnt := state_machine.InFlightCalls(&entry, now); cnt != 2 {
+		t.Fatalf("Expected 2 calls in flight, but got: %d", cnt)
+	}
+	if stats := state_machine.Stats(&entry, now); stats.CallsCnt != 1 {
+		t.Fatalf("Expected the reported call to be counted, but got: %+v", stats)
+	}
+}
+
+func TestBulkheadExpiresUnreleasedLeases(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			MaxConcurrentCalls: 1,
+			PermitTimeoutMs:    1000,
+		},
+	}
+	leaked := state_machine.Acquire(&entry, now)
+
+	// Act
+	rejected := state_machine.Acquire(&entry, now.Add(500*time.Millisecond))
+	afterTimeout := state_machine.Acquire(&entry, now.Add(2*time.Second))
+
+	// Assert
+	if !leaked.Allowed || rejected.Allowed {
+		t.Fatalf("Expected the second call to be rejected while the lease is held, but got: %+v, %+v", leaked, rejected)
+	}
+	if !afterTimeout.Allowed || afterTimeout.PermitID == leaked.PermitID {
+		t.Fatalf("Expected the expired lease to be released, but got: %+v", afterTimeout)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{
+		DeviceID: 1,
+		State:    model.StateClosed,
+		BreakerConfig: model.BreakerConfig{
+			WindowLengthMs:        10000,
+			RetryBudgetPercentage: 20,
+			RetryBudgetMinRetries: 1,
+		},
+	}
+
+	// Act
+	allowedWithoutCalls := state_machine.AllowRetry(&entry, now)
+	state_machine.Report(&entry, state_machine.Call{Retry: true}, now)
+	deniedAfterMinRetries := state_machine.AllowRetry(&entry, now)
+	for range 10 {
+		state_machine.Report(&entry, state_machine.Call{}, now)
+	}
+	allowedAfterFirstAttempts := state_machine.AllowRetry(&entry, now)
+	state_machine.Report(&entry, state_machine.Call{Retry: true}, now)
+	deniedAfterBudget := state_machine.AllowRetry(&entry, now)
+
+	// Assert
+	if !allowedWithoutCalls || deniedAfterMinRetries {
+		t.Fatalf("Expected exactly the minimum retries to be allowed, but got: %v, %v", allowedWithoutCalls, deniedAfterMinRetries)
+	}
+	if !allowedAfterFirstAttempts || deniedAfterBudget {
+		t.Fatalf("Expected 20%% of 10 first attempts to be allowed, but got: %v, %v", allowedAfterFirstAttempts, deniedAfterBudget)
+	}
+	if limit, ok := state_machine.RetriesLimit(&entry, state_machine.Stats(&entry, now)); !ok || limit != 2 {
+		t.Fatalf("Expected the limit of 2 retries, but got: %d, %v", limit, ok)
+	}
+	if !state_machine.AllowRetry(&entry, now.Add(time.Minute)) {
+		t.Fatalf("Expected the budget to be restored once the retries left the window")
+	}
+}
+
+func TestRetryWithoutBudgetAlwaysAllowed(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entry := model.CircuitBreakerEntry{DeviceID: 1, State: model.StateClosed}
+	state_machine.Report(&entry, state_machine.Call{Retry: true}, now)
+
+	// Act
+	allowed := state_machine.AllowRetry(&entry, now)
+
+	// Assert
+	if !allowed {
+		t.Fatalf("Expected the retry to be allowed without a budget, but got: %v", allowed)
+	}
+}
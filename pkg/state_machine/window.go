@@ -0,0 +1,166 @@
+package state_machine
+
+import (
+	"time"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// DefaultWindowBucketsCnt is used when the number of buckets of the time window is not configured.
+const DefaultWindowBucketsCnt = 10
+
+// DefaultWindowSize is used when the size of the count window is not configured.
+const DefaultWindowSize = 100
+
+// WindowStats represents the calls collected in the rolling window.
+type WindowStats struct {
+	CallsCnt     int
+	FailuresCnt  int
+	SlowCallsCnt int
+	RetriesCnt   int
+
+	CallsWeight    float64
+	FailuresWeight float64
+}
+
+// ErrorRate returns the percentage of failed calls, the failure weights are taken into account.
+func (s WindowStats) ErrorRate() float64 {
+	if s.CallsWeight == 0 {
+		return 0
+	}
+	return s.FailuresWeight * 100 / s.CallsWeight
+}
+
+// SlowCallRate returns the percentage of slow calls.
+func (s WindowStats) SlowCallRate() float64 {
+	return s.rate(s.SlowCallsCnt)
+}
+
+func (s WindowStats) rate(cnt int) float64 {
+	if s.CallsCnt == 0 {
+		return 0
+	}
+	return float64(cnt) * 100 / float64(s.CallsCnt)
+}
+
+// Stats returns the calls collected in the rolling window at the given moment.
+func Stats(entry *model.CircuitBreakerEntry, now time.Time) WindowStats {
+	var stats WindowStats
+	for _, bucket := range windowBuckets(entry, now) {
+		stats.CallsCnt += bucket.CallsCnt
+		stats.FailuresCnt += bucket.FailuresCnt
+		stats.SlowCallsCnt += bucket.SlowCallsCnt
+		stats.RetriesCnt += bucket.RetriesCnt
+		stats.CallsWeight += bucket.CallsWeight
+		stats.FailuresWeight += bucket.FailuresWeight
+	}
+	return stats
+}
+
+func countCall(entry *model.CircuitBreakerEntry, call Call, now time.Time) {
+	updateBaseline(entry, call)
+
+	weight := callWeight(entry, call)
+	bucket := currentBucket(entry, now)
+	bucket.CallsCnt++
+	bucket.CallsWeight += weight
+	if call.Failed {
+		bucket.FailuresCnt++
+		bucket.FailuresWeight += weight
+	}
+	if isSlow(entry, call) {
+		bucket.SlowCallsCnt++
+	}
+	if call.Retry {
+		bucket.RetriesCnt++
+	}
+}
+
+func isSlow(entry *model.CircuitBreakerEntry, call Call) bool {
+	if entry.SlowCallDurationMs <= 0 {
+		return false
+	}
+	return call.Duration >= time.Duration(entry.SlowCallDurationMs)*time.Millisecond
+}
+
+// windowBuckets returns the buckets which are still inside the rolling window.
+func windowBuckets(entry *model.CircuitBreakerEntry, now time.Time) []model.Bucket {
+	if isCountWindow(entry) {
+		size := windowSize(entry)
+		if len(entry.Window) > size {
+			return entry.Window[len(entry.Window)-size:]
+		}
+		return entry.Window
+	}
+
+	length, _ := timeWindowSettings(entry)
+	first := 0
+	for first < len(entry.Window) && isBucketExpired(entry.Window[first], length, now) {
+		first++
+	}
+	return entry.Window[first:]
+}
+
+// Time buckets are aligned to multiples of the bucket length and only non-empty buckets are stored,
+// so the window is at most WindowBucketsCnt + 1 entries long and needs no background rotation.
+// The count window stores each call in its own bucket and keeps the last WindowSize of them.
+func currentBucket(entry *model.CircuitBreakerEntry, now time.Time) *model.Bucket {
+	if isCountWindow(entry) {
+		size := windowSize(entry)
+		if len(entry.Window) >= size {
+			entry.Window = entry.Window[len(entry.Window)-size+1:]
+		}
+		entry.Window = append(entry.Window, model.Bucket{StartedAt: now})
+		return &entry.Window[len(entry.Window)-1]
+	}
+
+	entry.Window = windowBuckets(entry, now)
+
+	// the errors are never expired if the window length is not set, keep them in a single bucket
+	_, bucketLength := timeWindowSettings(entry)
+	startedAt := now
+	if bucketLength > 0 {
+		startedAt = now.Truncate(bucketLength)
+	}
+
+	last := len(entry.Window) - 1
+	if last < 0 || (bucketLength > 0 && !entry.Window[last].StartedAt.Equal(startedAt)) {
+		entry.Window = append(entry.Window, model.Bucket{StartedAt: startedAt})
+		last++
+	}
+	return &entry.Window[last]
+}
+
+func isBucketExpired(bucket model.Bucket, length time.Duration, now time.Time) bool {
+	if length <= 0 {
+		return false
+	}
+	return !bucket.StartedAt.After(now.Add(-length))
+}
+
+func isCountWindow(entry *model.CircuitBreakerEntry) bool {
+	return entry.WindowType == model.WindowTypeCount
+}
+
+func windowSize(entry *model.CircuitBreakerEntry) int {
+	if entry.WindowSize <= 0 {
+		return DefaultWindowSize
+	}
+	return entry.WindowSize
+}
+
+// timeWindowSettings returns the length of the time window and the length of one bucket.
+func timeWindowSettings(entry *model.CircuitBreakerEntry) (time.Duration, time.Duration) {
+	lengthMs := entry.WindowLengthMs
+	if lengthMs <= 0 {
+		lengthMs = entry.ErrorsCntResetTimeoutMs
+	}
+
+	bucketsCnt := entry.WindowBucketsCnt
+	if bucketsCnt <= 0 {
+		bucketsCnt = DefaultWindowBucketsCnt
+	}
+
+	length := time.Duration(lengthMs) * time.Millisecond
+	return length, length / time.Duration(bucketsCnt)
+}
@@ -0,0 +1,260 @@
+package storage_test_suite
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/generic_storage"
+	"github.com/maksym-shvaiuk/circuit-breaker-golang-test-excercise/pkg/model"
+)
+
+// The behavior tests shared by all generic_storage.StorageClient implementations of the circuit breakers,
+// so the backends are interchangeable. Each test gets an empty storage from open.
+
+type Storage = generic_storage.StorageClient[model.Key, model.CircuitBreakerEntry]
+
+// Run runs all behavior tests as the subtests of t.
+func Run(t *testing.T, open func(t *testing.T) Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, storage Storage)
+	}{
+		{"IsAlive", testIsAlive},
+		{"AddGetRemove", testAddGetRemove},
+		{"UpsertIncrementsVersion", testUpsertIncrementsVersion},
+		{"GetEntryReturnsCopy", testGetEntryReturnsCopy},
+		{"CompareAndSwapDetectsConflict", testCompareAndSwapDetectsConflict},
+		{"CompareAndSwapLosesNoUpdates", testCompareAndSwapLosesNoUpdates},
+		{"BatchReportsResultPerKey", testBatchReportsResultPerKey},
+		{"GetAllEntriesPaginatedOrderedByKey", testGetAllEntriesPaginatedOrderedByKey},
+		{"GetAllEntriesPaginatedWithHugePageSize", testGetAllEntriesPaginatedWithHugePageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, open(t))
+		})
+	}
+}
+
+func testIsAlive(t *testing.T, storage Storage) {
+	// Act
+	err := storage.IsAlive(context.Background())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+}
+
+func testAddGetRemove(t *testing.T, storage Storage) {
+	// Arrange
+	ctx := context.Background()
+	entry := model.CircuitBreakerEntry{DeviceID: 1, State: model.StateOpen, LastFailureReason: "timeout"}
+
+	// Act
+	added := storage.AddNewEntry(ctx, entry.DeviceID, entry)
+	duplicate := storage.AddNewEntry(ctx, entry.DeviceID, entry)
+	stored, getErr := storage.GetEntry(ctx, entry.DeviceID)
+	removed := storage.RemoveEntry(ctx, entry.DeviceID)
+	_, missing := storage.GetEntry(ctx, entry.DeviceID)
+	removedTwice := storage.RemoveEntry(ctx, entry.DeviceID)
+
+	// Assert
+	if added != nil || getErr != nil || removed != nil {
+		t.Fatalf("Expected no errors, but got: %v, %v, %v", added, getErr, removed)
+	}
+	if !errors.Is(duplicate, generic_storage.ErrEntryAlreadyExists) {
+		t.Fatalf("Expected %v, but got: %v", generic_storage.ErrEntryAlreadyExists, duplicate)
+	}
+	if stored.State != model.StateOpen || stored.LastFailureReason != "timeout" || stored.Version != 1 {
+		t.Fatalf("Expected the stored entry of version 1, but got: %+v", stored)
+	}
+	if !errors.Is(missing, generic_storage.ErrEntryNotFound) || !errors.Is(removedTwice, generic_storage.ErrEntryNotFound) {
+		t.Fatalf("Expected %v, but got: %v, %v", generic_storage.ErrEntryNotFound, missing, removedTwice)
+	}
+}
+
+func testUpsertIncrementsVersion(t *testing.T, storage Storage) {
+	// Arrange
+	ctx := context.Background()
+	entry := model.CircuitBreakerEntry{DeviceID: 1}
+
+	// Act
+	_ = storage.UpsertEntry(ctx, entry.DeviceID, entry)
+	entry.ConsecutiveTripsCnt = 3
+	_ = storage.UpsertEntry(ctx, entry.DeviceID, entry)
+
+	// Assert
+	if stored, _ := storage.GetEntry(ctx, entry.DeviceID); stored.Version != 2 || stored.ConsecutiveTripsCnt != 3 {
+		t.Fatalf("Expected the updated entry of version 2, but got: %+v", stored)
+	}
+}
+
+func testGetEntryReturnsCopy(t *testing.T, storage Storage) {
+	// Arrange
+	ctx := context.Background()
+	parentID := model.Key(10)
+	_ = storage.AddNewEntry(ctx, 1, model.CircuitBreakerEntry{DeviceID: 1, ParentID: &parentID, Members: []model.Key{2}})
+
+	// Act
+	stored, _ := storage.GetEntry(ctx, 1)
+	*stored.ParentID = 20
+	stored.Members[0] = 3
+
+	// Assert
+	if stored, _ = storage.GetEntry(ctx, 1); *stored.ParentID != 10 || stored.Members[0] != 2 {
+		t.Fatalf("Expected the stored entry to stay unchanged, but got: %+v", stored)
+	}
+}
+
+func testCompareAndSwapDetectsConflict(t *testing.T, storage Storage) {
+	// Arrange
+	ctx := context.Background()
+	entry := model.CircuitBreakerEntry{DeviceID: 101, State: model.StateClosed}
+
+	// Act
+	created := storage.CompareAndSwap(ctx, entry.DeviceID, 0, entry)
+	duplicate := storage.CompareAndSwap(ctx, entry.DeviceID, 0, entry)
+	stored, _ := storage.GetEntry(ctx, entry.DeviceID)
+	stored.State = model.StateOpen
+	updated := storage.CompareAndSwap(ctx, entry.DeviceID, stored.Version, stored)
+	stale := storage.CompareAndSwap(ctx, entry.DeviceID, stored.Version, stored)
+
+	// Assert
+	if created != nil || updated != nil {
+		t.Fatalf("Expected no errors, but got: %v, %v", created, updated)
+	}
+	if !errors.Is(duplicate, generic_storage.ErrConflict) || !errors.Is(stale, generic_storage.ErrConflict) {
+		t.Fatalf("Expected %v, but got: %v, %v", generic_storage.ErrConflict, duplicate, stale)
+	}
+	if stored, _ = storage.GetEntry(ctx, entry.DeviceID); stored.Version != 2 || stored.State != model.StateOpen {
+		t.Fatalf("Expected version 2 in %v state, but got: %d, %v", model.StateOpen, stored.Version, stored.State)
+	}
+}
+
+func testCompareAndSwapLosesNoUpdates(t *testing.T, storage Storage) {
+	// Arrange
+	ctx := context.Background()
+	deviceID := model.Key(102)
+	_ = storage.AddNewEntry(ctx, deviceID, model.CircuitBreakerEntry{DeviceID: deviceID})
+	const workersCnt, incrementsCnt = 8, 50
+
+	// Act
+	var wg sync.WaitGroup
+	for range workersCnt {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range incrementsCnt {
+				for {
+					entry, _ := storage.GetEntry(ctx, deviceID)
+					entry.ConsecutiveTripsCnt++
+					entry.Members = append(entry.Members, deviceID)
+					if storage.CompareAndSwap(ctx, deviceID, entry.Version, entry) == nil {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	entry, _ := storage.GetEntry(ctx, deviceID)
+	if entry.ConsecutiveTripsCnt != workersCnt*incrementsCnt || len(entry.Members) != workersCnt*incrementsCnt {
+		t.Fatalf("Expected %d increments, but got: %d, %d members", workersCnt*incrementsCnt, entry.ConsecutiveTripsCnt, len(entry.Members))
+	}
+}
+
+func testBatchReportsResultPerKey(t *testing.T, storage Storage) {
+	// Arrange
+	ctx := context.Background()
+	_ = storage.AddNewEntry(ctx, 201, model.CircuitBreakerEntry{DeviceID: 201})
+	batch := []generic_storage.BatchEntry[model.Key, model.CircuitBreakerEntry]{
+		{PrimaryKey: 201, Entry: model.CircuitBreakerEntry{DeviceID: 201}},
+		{PrimaryKey: 202, Entry: model.CircuitBreakerEntry{DeviceID: 202}},
+	}
+
+	// Act
+	added, err := storage.AddNewEntriesBatch(ctx, batch)
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+	batch[0].ExpectedVersion, batch[1].ExpectedVersion = 1, 7
+	swapped, _ := storage.CompareAndSwapBatch(ctx, batch)
+	upserted, _ := storage.UpsertEntriesBatch(ctx, batch)
+	removed, _ := storage.RemoveEntriesBatch(ctx, []model.Key{202, 203})
+
+	// Assert
+	if !errors.Is(added[0], generic_storage.ErrEntryAlreadyExists) || added[1] != nil {
+		t.Fatalf("Expected only the new entry to be added, but got: %v", added)
+	}
+	if swapped[0] != nil || !errors.Is(swapped[1], generic_storage.ErrConflict) {
+		t.Fatalf("Expected only the entry of the matching version to be swapped, but got: %v", swapped)
+	}
+	if len(upserted) != 2 || upserted[0] != nil || upserted[1] != nil {
+		t.Fatalf("Expected both entries to be upserted, but got: %v", upserted)
+	}
+	if removed[0] != nil || !errors.Is(removed[1], generic_storage.ErrEntryNotFound) {
+		t.Fatalf("Expected only the existing entry to be removed, but got: %v", removed)
+	}
+	if entry, _ := storage.GetEntry(ctx, 201); entry.Version != 3 {
+		t.Fatalf("Expected version 3, but got: %d", entry.Version)
+	}
+}
+
+func testGetAllEntriesPaginatedOrderedByKey(t *testing.T, storage Storage) {
+	// Arrange
+	ctx := context.Background()
+	// the keys differ in the higher bytes, so the order breaks if the keys are not stored big-endian
+	for _, deviceID := range []model.Key{65536, 2, 256, 1, 255} {
+		_ = storage.AddNewEntry(ctx, deviceID, model.CircuitBreakerEntry{DeviceID: deviceID})
+	}
+	removed := model.Key(2)
+	_ = storage.RemoveEntry(ctx, removed)
+
+	// Act
+	first, _ := storage.GetAllEntriesPaginated(ctx, nil, 2)
+	second, _ := storage.GetAllEntriesPaginated(ctx, &removed, 2)
+	last, _ := storage.GetAllEntriesPaginated(ctx, &second[1].DeviceID, 2)
+	keys, _ := storage.GetAllPrimaryKeys(ctx)
+	all, _ := storage.GetAllEntries(ctx)
+
+	// Assert
+	deviceIDs := func(entries []model.CircuitBreakerEntry) []model.Key {
+		var ids []model.Key
+		for _, entry := range entries {
+			ids = append(ids, entry.DeviceID)
+		}
+		return ids
+	}
+	if !slices.Equal(deviceIDs(first), []model.Key{1, 255}) || !slices.Equal(deviceIDs(second), []model.Key{255, 256}) ||
+		!slices.Equal(deviceIDs(last), []model.Key{65536}) {
+		t.Fatalf("Expected pages [1 255], [255 256] and [65536], but got: %v, %v, %v", deviceIDs(first), deviceIDs(second), deviceIDs(last))
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []model.Key{1, 255, 256, 65536}) || len(all) != 4 {
+		t.Fatalf("Expected 4 keys and entries, but got: %v, %d entries", keys, len(all))
+	}
+}
+
+func testGetAllEntriesPaginatedWithHugePageSize(t *testing.T, storage Storage) {
+	// Arrange
+	ctx := context.Background()
+	for _, deviceID := range []model.Key{1, 2} {
+		_ = storage.AddNewEntry(ctx, deviceID, model.CircuitBreakerEntry{DeviceID: deviceID})
+	}
+
+	// Act
+	entries, err := storage.GetAllEntriesPaginated(ctx, nil, math.MaxInt)
+
+	// Assert
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected all 2 entries, but got: %d, %v", len(entries), err)
+	}
+}